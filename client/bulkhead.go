@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBulkheadFull 服务并发数已满且排队已满或排队超时
+var ErrBulkheadFull = errors.New("微服务并发请求数已满")
+
+// bulkhead 限制对每个服务的并发请求数，超出时排队等待
+type bulkhead struct {
+	slots    chan struct{}
+	maxQueue int32
+	queued   int32
+	inFlight int32
+	timeout  time.Duration
+}
+
+var bulkheads sync.Map
+
+// getBulkhead 按bulkhead.maxConcurrent、bulkhead.maxQueue、bulkhead.queueTimeout配置创建服务的并发限制
+// maxConcurrent为0时不限制并发，只统计进行中的请求数
+func getBulkhead(service string) *bulkhead {
+	if b, ok := bulkheads.Load(service); ok {
+		return b.(*bulkhead)
+	}
+	b := &bulkhead{
+		maxQueue: int32(clientConfigInt(service, "bulkhead.maxQueue", 0)),
+		timeout:  time.Duration(clientConfigInt(service, "bulkhead.queueTimeout", 1000)) * time.Millisecond,
+	}
+	if max := clientConfigInt(service, "bulkhead.maxConcurrent", 0); max > 0 {
+		b.slots = make(chan struct{}, max)
+	}
+	v, _ := bulkheads.LoadOrStore(service, b)
+	return v.(*bulkhead)
+}
+
+func (b *bulkhead) acquire(ctx context.Context) error {
+	if b.slots != nil {
+		select {
+		case b.slots <- struct{}{}:
+		default:
+			if err := b.wait(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	atomic.AddInt32(&b.inFlight, 1)
+	return nil
+}
+
+func (b *bulkhead) wait(ctx context.Context) error {
+	if atomic.AddInt32(&b.queued, 1) > b.maxQueue {
+		atomic.AddInt32(&b.queued, -1)
+		return ErrBulkheadFull
+	}
+	defer atomic.AddInt32(&b.queued, -1)
+	timer := time.NewTimer(b.timeout)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrBulkheadFull
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *bulkhead) release() {
+	atomic.AddInt32(&b.inFlight, -1)
+	if b.slots != nil {
+		<-b.slots
+	}
+}
+
+// releaseOnClose 响应体关闭时释放并发占用
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// InFlight 获取对某个服务正在进行中的请求数
+func InFlight(service string) int {
+	if b, ok := bulkheads.Load(service); ok {
+		return int(atomic.LoadInt32(&b.(*bulkhead).inFlight))
+	}
+	return 0
+}
+
+// InFlightAll 获取对所有服务正在进行中的请求数
+func InFlightAll() map[string]int {
+	counts := make(map[string]int)
+	bulkheads.Range(func(key, value interface{}) bool {
+		counts[key.(string)] = int(atomic.LoadInt32(&value.(*bulkhead).inFlight))
+		return true
+	})
+	return counts
+}
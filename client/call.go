@@ -0,0 +1,315 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/errcode"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/mask"
+	"github.com/maczh/mgin/middleware/trace"
+	"github.com/maczh/mgin/scope"
+	"github.com/maczh/mgin/tracing"
+	"github.com/maczh/mgin/utils"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// callOptions 一次微服务调用的请求内容
+type callOptions struct {
+	ctx     context.Context
+	method  string
+	service string
+	uri     string
+	headers map[string]string
+	query   map[string]string
+	form    map[string]string
+	json    interface{}
+}
+
+// 从上游请求透传header时不能转发的头
+var skipHeaders = map[string]bool{
+	"Accept-Encoding":         true,
+	"Connection":              true,
+	"Content-Length":          true,
+	"Host":                    true,
+	"Transfer-Encoding":       true,
+	utils.HeaderSignature:     true,
+	utils.HeaderTimestamp:     true,
+	utils.HeaderNonce:         true,
+	utils.HeaderCaller:        true,
+	utils.HeaderContentSha256: true,
+	HeaderFaultInject:         true,
+}
+
+// callHeaders 合并当前请求链路的header与调用方传入的header，链路header优先
+// ctx中没有请求作用域时从当前协程的缓存中获取链路header
+func callHeaders(ctx context.Context, header interface{}) map[string]string {
+	var headers map[string]string
+	if s := scope.FromContext(ctx); s != nil {
+		headers = s.CopyHeaders()
+	} else {
+		headers = trace.GetHeaders()
+	}
+	if header != nil {
+		h := utils.AnyToMap(header)
+		for k, v := range h {
+			if headers[k] == "" {
+				headers[k] = v
+			}
+		}
+	}
+	return headers
+}
+
+// callTimeout 通过X-Timeout来控制链路接口请求超时
+func callTimeout(headers map[string]string) time.Duration {
+	timeout := 90 * time.Second
+	t := headers["X-Timeout"]
+	if t != "" {
+		ti, _ := strconv.Atoi(t)
+		if ti > 0 {
+			timeout = time.Duration(ti) * time.Second
+		}
+	}
+	return timeout
+}
+
+// encodeBody 按表单或JSON方式编码请求体
+func (o *callOptions) encodeBody() ([]byte, string, error) {
+	switch {
+	case o.json != nil:
+		switch b := o.json.(type) {
+		case string:
+			return []byte(b), "application/json", nil
+		case []byte:
+			return b, "application/json", nil
+		default:
+			data, err := json.Marshal(b)
+			return data, "application/json", err
+		}
+	case o.form != nil:
+		values := url.Values{}
+		for k, v := range o.form {
+			values.Set(k, v)
+		}
+		return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+	}
+	return nil, "", nil
+}
+
+// context 调用所在请求的ctx，未指定时为context.Background()
+func (o *callOptions) context() context.Context {
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+func (o *callOptions) params() interface{} {
+	if o.json != nil {
+		return o.json
+	}
+	if o.form != nil {
+		return o.form
+	}
+	return o.query
+}
+
+// callResponse 微服务调用的返回结果
+type callResponse struct {
+	statusCode int
+	header     http.Header
+	body       string
+}
+
+// call 执行微服务调用，返回响应内容
+func call(o *callOptions) (string, error) {
+	var resp *callResponse
+	var err error
+	if o.cacheable() {
+		resp, err = cachedExecute(o)
+	} else {
+		resp, err = executeCall(o)
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.body, nil
+}
+
+// executeCall 执行微服务请求，开启对冲请求时使用对冲方式执行
+func executeCall(o *callOptions) (*callResponse, error) {
+	if o.hedgeable() {
+		return hedgedExecute(o)
+	}
+	return execute(o)
+}
+
+// execute 执行微服务请求，连接被拒绝时刷新服务地址重试一次
+func execute(o *callOptions) (*callResponse, error) {
+	host, err := getServiceHost(o.service)
+	if err != nil {
+		return nil, err
+	}
+	body, contentType, err := o.encodeBody()
+	if err != nil {
+		return nil, err
+	}
+	logs.WithContext(o.context()).Debug("Nacos微服务请求:{}\n请求参数:{}\n请求头:{}", host+o.uri, mask.Value(o.params()), mask.Headers(o.headers))
+	resp, err := o.send(o.context(), host, body, contentType)
+	if err != nil && strings.Contains(err.Error(), "connection refused") {
+		host, err = refreshServiceHost(o.service)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = o.send(o.context(), host, body, contentType)
+	}
+	if err != nil {
+		return nil, callError(err)
+	}
+	logs.WithContext(o.context()).Debug("Nacos微服务返回结果:{}", mask.JSON(resp.body))
+	return resp, nil
+}
+
+// ErrServiceUnavailable 无法连接到服务
+var ErrServiceUnavailable = errors.New("Service unavailable")
+
+func callError(err error) error {
+	if strings.Contains(err.Error(), "dial tcp") {
+		return ErrServiceUnavailable
+	}
+	return err
+}
+
+// ErrorCode 将微服务调用错误转换为错误代码，服务不可用与并发数已满时为errcode.SERVICE_UNAVAILABLE
+func ErrorCode(err error) int {
+	switch err {
+	case nil:
+		return 1
+	case ErrServiceUnavailable, ErrBulkheadFull:
+		return errcode.SERVICE_UNAVAILABLE
+	default:
+		return -1
+	}
+}
+
+func (o *callOptions) send(ctx context.Context, host string, body []byte, contentType string) (*callResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout(o.headers))
+	defer cancel()
+	req, err := o.newRequest(ctx, host, bytes.NewReader(body), utils.Sha256(string(body)), contentType)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &callResponse{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       string(data),
+	}, nil
+}
+
+// newRequest 生成请求，带上query参数、透传的header，并按配置签名
+func (o *callOptions) newRequest(ctx context.Context, host string, body io.Reader, bodyHash, contentType string) (*http.Request, error) {
+	u, err := url.Parse(host + o.uri)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.query) > 0 {
+		q := u.Query()
+		for k, v := range o.query {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, o.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range o.headers {
+		if !skipHeaders[http.CanonicalHeaderKey(k)] {
+			req.Header.Set(k, v)
+		}
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	//请求的ctx有超时时间时，将剩余时间通过X-Timeout传给下游服务
+	if deadline, ok := o.context().Deadline(); ok {
+		timeout := int(math.Ceil(time.Until(deadline).Seconds()))
+		if timeout < 1 {
+			timeout = 1
+		}
+		req.Header.Set("X-Timeout", strconv.Itoa(timeout))
+	}
+	if key := clientConfigString(o.service, "sign.key", ""); key != "" {
+		signRequest(req, key, bodyHash)
+	}
+	return req, nil
+}
+
+// do 通过服务的共享连接池发送请求，受服务并发数限制，响应体关闭后释放占用
+// 非生产环境下可按配置或请求头注入故障
+func (o *callOptions) do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	//调用时未传入带span的ctx，以透传header中的traceparent为父span
+	if !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		ctx = tracing.Extract(ctx, o.headers)
+	}
+	ctx, span := tracing.Start(ctx, o.method+" "+o.service+o.uri, oteltrace.SpanKindClient,
+		append(semconv.HTTPClientAttributesFromHTTPRequest(req), semconv.PeerServiceKey.String(o.service))...)
+	req = req.WithContext(ctx)
+	tracing.InjectHTTP(ctx, req.Header)
+
+	b := getBulkhead(o.service)
+	if err := b.acquire(ctx); err != nil {
+		logs.WithContext(ctx).Error("微服务{}并发请求受限:{}", o.service, err.Error())
+		tracing.End(span, err)
+		return nil, err
+	}
+	resp, err := o.injectFault(ctx)
+	if resp == nil && err == nil {
+		resp, err = getHttpClient(o.service).Do(req)
+	}
+	if err != nil {
+		b.release()
+		tracing.End(span, err)
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(resp.StatusCode, oteltrace.SpanKindClient))
+	resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: func() {
+		b.release()
+		span.End()
+	}}
+	return resp, nil
+}
+
+// signRequest 使用被调用服务的签名密钥对请求签名
+func signRequest(req *http.Request, key string, bodyHash string) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := utils.GetRandomHexString(16)
+	req.Header.Set(utils.HeaderTimestamp, timestamp)
+	req.Header.Set(utils.HeaderNonce, nonce)
+	req.Header.Set(utils.HeaderCaller, config.Current().App.Name)
+	req.Header.Set(utils.HeaderContentSha256, bodyHash)
+	req.Header.Set(utils.HeaderSignature, utils.RequestSign(key, req.Method, req.URL.Path, req.URL.Query(), bodyHash, timestamp, nonce))
+}
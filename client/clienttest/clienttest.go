@@ -0,0 +1,168 @@
+// Package clienttest 提供不依赖Nacos与网络的微服务调用测试工具
+// 通过client.SetDiscovery与client.SetTransport替换服务发现与RoundTripper，将微服务调用转发到进程内的桩接口或gin.Engine
+package clienttest
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/cache"
+	"github.com/maczh/mgin/client"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// 测试服务的主机名后缀
+const hostSuffix = ".clienttest"
+
+// Call 一次被记录的微服务调用
+type Call struct {
+	Service string
+	Method  string
+	Uri     string
+	Query   url.Values
+	Header  http.Header
+	Body    string
+}
+
+// Server 进程内的假微服务集合
+type Server struct {
+	mu      sync.Mutex
+	engines map[string]http.Handler
+	stubs   map[string]*gin.Engine
+	calls   []Call
+}
+
+// New 创建假微服务集合，并将client的服务发现与请求转发到其中，测试结束时须调用Close
+func New() *Server {
+	s := &Server{
+		engines: make(map[string]http.Handler),
+		stubs:   make(map[string]*gin.Engine),
+	}
+	client.SetDiscovery(s)
+	client.SetTransport(s)
+	return s
+}
+
+// Close 恢复client原有的服务发现与连接池
+func (s *Server) Close() {
+	client.SetTransport(nil)
+	client.SetDiscovery(nil)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for service := range s.engines {
+		cache.OnGetCache("nacos").Delete(service)
+	}
+	for service := range s.stubs {
+		cache.OnGetCache("nacos").Delete(service)
+	}
+}
+
+// Mount 将一个真实的gin.Engine或http.Handler作为服务挂载到进程内
+func (s *Server) Mount(service string, handler http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.engines[service] = handler
+	cache.OnGetCache("nacos").Delete(service)
+}
+
+// Handle 为服务的接口注册桩处理函数，任意请求方法均可匹配
+func (s *Server) Handle(service, uri string, handler gin.HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	engine, ok := s.stubs[service]
+	if !ok {
+		gin.SetMode(gin.TestMode)
+		engine = gin.New()
+		s.stubs[service] = engine
+	}
+	engine.Any(uri, handler)
+	cache.OnGetCache("nacos").Delete(service)
+}
+
+// HandleJSON 为服务的接口注册固定返回JSON内容的桩
+func (s *Server) HandleJSON(service, uri string, result interface{}) {
+	s.Handle(service, uri, func(c *gin.Context) {
+		c.JSON(http.StatusOK, result)
+	})
+}
+
+// Calls 获取全部已记录的调用
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call{}, s.calls...)
+}
+
+// CallsTo 获取对某个服务接口的调用记录
+func (s *Server) CallsTo(service, uri string) []Call {
+	calls := make([]Call, 0)
+	for _, c := range s.Calls() {
+		if c.Service == service && c.Uri == uri {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset 清空调用记录
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+}
+
+// GetServiceURL 实现registry.Discovery，已挂载或注册了桩的服务返回进程内地址
+func (s *Server) GetServiceURL(serviceName string) (string, string) {
+	if s.handler(serviceName) == nil {
+		return "", ""
+	}
+	return "http://" + serviceName + hostSuffix, "DEFAULT_GROUP"
+}
+
+func (s *Server) handler(service string) http.Handler {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.engines[service]; ok {
+		return h
+	}
+	if h, ok := s.stubs[service]; ok {
+		return h
+	}
+	return nil
+}
+
+// RoundTrip 实现http.RoundTripper，记录调用并交给进程内的服务处理
+func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
+	service := strings.TrimSuffix(req.URL.Hostname(), hostSuffix)
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{
+		Service: service,
+		Method:  req.Method,
+		Uri:     req.URL.Path,
+		Query:   req.URL.Query(),
+		Header:  req.Header.Clone(),
+		Body:    string(body),
+	})
+	s.mu.Unlock()
+
+	recorder := httptest.NewRecorder()
+	h := s.handler(service)
+	if h == nil {
+		recorder.WriteHeader(http.StatusNotFound)
+		return recorder.Result(), nil
+	}
+	in := req.Clone(req.Context())
+	in.Body = ioutil.NopCloser(bytes.NewReader(body))
+	in.RequestURI = req.URL.RequestURI()
+	h.ServeHTTP(recorder, in)
+	return recorder.Result(), nil
+}
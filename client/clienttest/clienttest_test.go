@@ -0,0 +1,117 @@
+package clienttest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/cache"
+	"github.com/maczh/mgin/client"
+	"github.com/maczh/mgin/registry"
+)
+
+// staticDiscovery 固定返回一个地址的服务发现，用于验证Close后恢复原有配置
+type staticDiscovery string
+
+func (d staticDiscovery) GetServiceURL(serviceName string) (string, string) {
+	return string(d), "DEFAULT_GROUP"
+}
+
+func TestHandle(t *testing.T) {
+	s := New()
+	defer s.Close()
+	s.Handle("user", "/user/get", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": 1, "msg": "ok", "data": c.PostForm("id")})
+	})
+
+	res, err := client.Nacos.Call("user", "/user/get", map[string]string{"id": "42"})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if !strings.Contains(res, `"data":"42"`) {
+		t.Errorf("Call返回%s", res)
+	}
+	calls := s.CallsTo("user", "/user/get")
+	if len(calls) != 1 {
+		t.Fatalf("CallsTo返回%d次调用", len(calls))
+	}
+	if calls[0].Method != http.MethodPost || calls[0].Body != "id=42" {
+		t.Errorf("记录的调用为%s %q", calls[0].Method, calls[0].Body)
+	}
+}
+
+func TestMount(t *testing.T) {
+	s := New()
+	defer s.Close()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.PUT("/order/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": 1, "id": c.Param("id"), "token": c.GetHeader("X-Token"), "type": c.Query("type")})
+	})
+	s.Mount("order", engine)
+
+	res, err := client.RestfulWithHeader("PUT", "order", "/order/{id}", map[string]string{"id": "7"},
+		map[string]string{"type": "vip"}, map[string]string{"X-Token": "abc"}, map[string]interface{}{"num": 2})
+	if err != nil {
+		t.Fatalf("RestfulWithHeader: %v", err)
+	}
+	for _, want := range []string{`"id":"7"`, `"token":"abc"`, `"type":"vip"`} {
+		if !strings.Contains(res, want) {
+			t.Errorf("RestfulWithHeader返回%s，缺少%s", res, want)
+		}
+	}
+	calls := s.CallsTo("order", "/order/7")
+	if len(calls) != 1 {
+		t.Fatalf("CallsTo返回%d次调用", len(calls))
+	}
+	if calls[0].Query.Get("type") != "vip" || calls[0].Header.Get("X-Token") != "abc" || calls[0].Body != `{"num":2}` {
+		t.Errorf("记录的调用为%+v", calls[0])
+	}
+	if len(s.CallsTo("order", "/order/8")) != 0 {
+		t.Error("CallsTo返回了其他接口的调用")
+	}
+	s.Reset()
+	if len(s.Calls()) != 0 {
+		t.Error("Reset后仍有调用记录")
+	}
+}
+
+func TestUnknownService(t *testing.T) {
+	s := New()
+	defer s.Close()
+	if _, err := client.RestfulWithHeader("GET", "missing", "/ping", nil, nil, nil, nil); err == nil {
+		t.Error("调用未注册的服务应返回错误")
+	}
+}
+
+func TestCloseRestores(t *testing.T) {
+	real := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":1,"from":"real"}`))
+	}))
+	defer real.Close()
+	//未配置go.discovery.registry时使用名为nacos的服务发现
+	previous := registry.Get("nacos")
+	registry.Register("nacos", staticDiscovery(real.URL))
+	defer func() {
+		registry.Register("nacos", previous)
+		cache.OnGetCache("nacos").Delete("echo")
+	}()
+
+	s := New()
+	s.HandleJSON("echo", "/echo", gin.H{"status": 1, "from": "stub"})
+	res, err := client.RestfulWithHeader("GET", "echo", "/echo", nil, nil, nil, nil)
+	if err != nil || !strings.Contains(res, `"from":"stub"`) {
+		t.Fatalf("New后调用返回%s, %v", res, err)
+	}
+	s.Close()
+
+	res, err = client.RestfulWithHeader("GET", "echo", "/echo", nil, nil, nil, nil)
+	if err != nil || !strings.Contains(res, `"from":"real"`) {
+		t.Errorf("Close后调用返回%s, %v", res, err)
+	}
+	if len(s.CallsTo("echo", "/echo")) != 1 {
+		t.Error("Close后的调用不应被记录")
+	}
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/logs"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderFaultInject 非生产环境下开启go.client.fault.header后通过请求头注入故障，请求头不会向下游透传
+// 格式为分号分隔的键值对，如 service=mgin-server;uri=/api/v1/user;delay=500;status=503;abort=false;percent=100
+const HeaderFaultInject = "X-Fault-Inject"
+
+// ErrFaultAbort 故障注入模拟的连接中断
+var ErrFaultAbort = errors.New("fault injection: connection reset by peer")
+
+// faultRule 故障注入规则
+type faultRule struct {
+	uris    []string
+	percent int
+	delay   time.Duration
+	status  int
+	abort   bool
+}
+
+// isProduction 生产环境不允许注入故障
+func isProduction() bool {
+	env := strings.ToLower(config.Current().Config.Env)
+	return env == "prod" || env == "production"
+}
+
+// configFaultRule 配置中的故障注入规则，配置在go.client.fault或go.client.services.<服务名>.fault下，修改配置文件后自动生效
+func configFaultRule(service string) *faultRule {
+	if !clientConfigBool(service, "fault.enable", false) {
+		return nil
+	}
+	rule := &faultRule{
+		percent: clientConfigInt(service, "fault.percent", 100),
+		delay:   time.Duration(clientConfigInt(service, "fault.delay", 0)) * time.Millisecond,
+		status:  clientConfigInt(service, "fault.status", 0),
+		abort:   clientConfigBool(service, "fault.abort", false),
+	}
+	if uris := clientConfigString(service, "fault.uris", ""); uris != "" {
+		rule.uris = strings.Split(uris, ",")
+	}
+	return rule
+}
+
+// headerFaultRule 请求头中的故障注入规则，需在配置中开启fault.header
+func headerFaultRule(service string, headers map[string]string) *faultRule {
+	if !clientConfigBool(service, "fault.header", false) {
+		return nil
+	}
+	value := headers[HeaderFaultInject]
+	if value == "" {
+		return nil
+	}
+	rule := &faultRule{percent: 100}
+	for _, kv := range strings.Split(value, ";") {
+		pair := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(pair) != 2 {
+			continue
+		}
+		switch pair[0] {
+		case "service":
+			if pair[1] != service {
+				return nil
+			}
+		case "uri":
+			rule.uris = []string{pair[1]}
+		case "percent":
+			rule.percent, _ = strconv.Atoi(pair[1])
+		case "delay":
+			ms, _ := strconv.Atoi(pair[1])
+			rule.delay = time.Duration(ms) * time.Millisecond
+		case "status":
+			rule.status, _ = strconv.Atoi(pair[1])
+		case "abort":
+			rule.abort = pair[1] == "true"
+		}
+	}
+	return rule
+}
+
+func (r *faultRule) match(uri string) bool {
+	if len(r.uris) > 0 {
+		matched := false
+		for _, u := range r.uris {
+			if strings.HasPrefix(uri, strings.TrimSpace(u)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return rand.Intn(100) < r.percent
+}
+
+// injectFault 按规则延迟、中断连接或直接返回指定的HTTP状态码，未注入状态码与中断时返回nil
+func (o *callOptions) injectFault(ctx context.Context) (*http.Response, error) {
+	if isProduction() {
+		return nil, nil
+	}
+	rule := headerFaultRule(o.service, o.headers)
+	if rule == nil {
+		rule = configFaultRule(o.service)
+	}
+	if rule == nil || !rule.match(o.uri) {
+		return nil, nil
+	}
+	logs.WithContext(ctx).Warn("微服务{}{}注入故障:延迟{}ms,状态码{},中断{}", o.service, o.uri, rule.delay.Milliseconds(), rule.status, rule.abort)
+	if rule.delay > 0 {
+		timer := time.NewTimer(rule.delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+	if rule.abort {
+		return nil, ErrFaultAbort
+	}
+	if rule.status > 0 {
+		body := fmt.Sprintf(`{"status":-1,"msg":"fault injection %d"}`, rule.status)
+		return &http.Response{
+			StatusCode:    rule.status,
+			Status:        fmt.Sprintf("%d %s", rule.status, http.StatusText(rule.status)),
+			Header:        http.Header{"Content-Type": []string{"application/json"}},
+			Body:          ioutil.NopCloser(bytes.NewBufferString(body)),
+			ContentLength: int64(len(body)),
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/mask"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 对冲请求预算的统计窗口
+const hedgeBudgetWindow = 10 * time.Second
+
+// hedgeBudget 限制对冲请求数量不超过统计窗口内请求数的一定比例
+type hedgeBudget struct {
+	sync.Mutex
+	start    time.Time
+	requests int
+	hedges   int
+}
+
+var hedgeBudgets sync.Map
+
+type hedgeResult struct {
+	resp *callResponse
+	err  error
+}
+
+// hedgeable 是否使用对冲请求，仅在服务开启hedge.enable时对GET请求生效
+// 可通过hedge.uris限定只对指定的接口使用对冲请求
+func (o *callOptions) hedgeable() bool {
+	if o.method != "GET" || !clientConfigBool(o.service, "hedge.enable", false) {
+		return false
+	}
+	uris := clientConfigString(o.service, "hedge.uris", "")
+	if uris == "" {
+		return true
+	}
+	for _, uri := range strings.Split(uris, ",") {
+		if strings.TrimSpace(uri) == o.uri {
+			return true
+		}
+	}
+	return false
+}
+
+func getHedgeBudget(service string) *hedgeBudget {
+	b, _ := hedgeBudgets.LoadOrStore(service, &hedgeBudget{start: time.Now()})
+	return b.(*hedgeBudget)
+}
+
+func (b *hedgeBudget) roll() {
+	if time.Since(b.start) > hedgeBudgetWindow {
+		b.start = time.Now()
+		b.requests = 0
+		b.hedges = 0
+	}
+}
+
+func (b *hedgeBudget) request() {
+	b.Lock()
+	defer b.Unlock()
+	b.roll()
+	b.requests++
+}
+
+// acquire 申请发送一次对冲请求，percent为允许对冲的请求比例，每个窗口至少允许一次
+func (b *hedgeBudget) acquire(percent int) bool {
+	b.Lock()
+	defer b.Unlock()
+	b.roll()
+	if b.hedges >= b.requests*percent/100+1 {
+		return false
+	}
+	b.hedges++
+	return true
+}
+
+// hedgedExecute 先向一个实例发送请求，超过hedge.delay毫秒未返回时向另一个实例再发送一次
+// 取先成功返回的结果并取消另一个请求
+func hedgedExecute(o *callOptions) (*callResponse, error) {
+	hosts, err := getServiceHosts(o.service)
+	if err != nil || len(hosts) < 2 {
+		if err == nil {
+			logs.WithContext(o.context()).Debug("微服务{}只有一个实例，不发送对冲请求", o.service)
+		}
+		return execute(o)
+	}
+	body, contentType, err := o.encodeBody()
+	if err != nil {
+		return nil, err
+	}
+	budget := getHedgeBudget(o.service)
+	budget.request()
+	percent := clientConfigInt(o.service, "hedge.budget", 10)
+	delay := time.Duration(clientConfigInt(o.service, "hedge.delay", 100)) * time.Millisecond
+
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	r.Shuffle(len(hosts), func(i, j int) { hosts[i], hosts[j] = hosts[j], hosts[i] })
+
+	ctx, cancel := context.WithCancel(o.context())
+	defer cancel()
+	results := make(chan hedgeResult, 2)
+	attempt := func(host string) {
+		resp, err := o.send(ctx, host, body, contentType)
+		results <- hedgeResult{resp: resp, err: err}
+	}
+	logs.WithContext(o.context()).Debug("Nacos微服务请求:{}\n请求参数:{}\n请求头:{}", hosts[0]+o.uri, mask.Value(o.params()), mask.Headers(o.headers))
+	go attempt(hosts[0])
+	pending, hedged := 1, false
+	hedge := func() {
+		hedged = true
+		if budget.acquire(percent) {
+			logs.WithContext(o.context()).Debug("微服务{}{}发送对冲请求到{}", o.service, o.uri, hosts[1])
+			pending++
+			go attempt(hosts[1])
+		}
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var last hedgeResult
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedge()
+			}
+		case last = <-results:
+			pending--
+			if last.err == nil && last.resp.statusCode < http.StatusInternalServerError {
+				logs.WithContext(o.context()).Debug("Nacos微服务返回结果:{}", mask.JSON(last.resp.body))
+				return last.resp, nil
+			}
+			//首个请求在对冲前失败时立即尝试另一个实例
+			if !hedged {
+				hedge()
+			}
+			if pending == 0 {
+				if last.err != nil {
+					return nil, callError(last.err)
+				}
+				return last.resp, nil
+			}
+		}
+	}
+}
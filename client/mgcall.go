@@ -1,15 +1,15 @@
 package client
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/levigross/grequests"
 	"github.com/maczh/mgin/cache"
 	"github.com/maczh/mgin/config"
 	"github.com/maczh/mgin/logs"
-	"github.com/maczh/mgin/middleware/trace"
 	"github.com/maczh/mgin/registry"
-	"github.com/maczh/mgin/utils"
+	"github.com/maczh/mgin/scope"
 	"github.com/nacos-group/nacos-sdk-go/model"
 	"github.com/nacos-group/nacos-sdk-go/vo"
 	"math/rand"
@@ -20,28 +20,42 @@ import (
 
 type mginClient struct {
 	reqType string
+	ctx     context.Context
 }
 
 var Nacos = &mginClient{}
 
+// WithContext 返回在ctx中调用的客户端，可直接传入*gin.Context
+// 调用时透传ctx中请求作用域的header，并受ctx的超时与取消控制
+func (c *mginClient) WithContext(ctx context.Context) *mginClient {
+	return &mginClient{reqType: c.reqType, ctx: scope.RequestContext(ctx)}
+}
+
+func (c *mginClient) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
 // Call 微服务调用其他服务的接口
 // x-form模式 Call(service string, uri string, params map[string]string)
 // json模式 Call(service string, uri string, method string, queryParams map[string]string, jsonBody interface{})
 // restful模式 Call(service string, uri string, method string, pathParams map[string]string, queryparams map[string]string, header map[string]string, jsonBody interface{})
 func (c *mginClient) Call(service string, uri string, params ...interface{}) (string, error) {
 	if c.reqType == "" {
-		c.reqType = config.Config.Discovery.CallType
+		c.reqType = config.Current().Discovery.CallType
 		if c.reqType == "" {
 			c.reqType = "x-form"
 		}
 	}
 	switch c.reqType {
 	case "x-form":
-		return CallWithHeader(service, uri, params[0], map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+		return form(c.context(), "POST", service, uri, nil, nil, params[0], map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
 	case "json":
 		var query interface{}
 		if len(params) == 0 {
-			return GetJson(service, uri, nil, nil)
+			return restful(c.context(), "GET", service, uri, nil, nil, nil, nil)
 		}
 		var body interface{}
 		if len(params) == 3 {
@@ -52,9 +66,9 @@ func (c *mginClient) Call(service string, uri string, params ...interface{}) (st
 		}
 
 		if params[0].(string) == "POST" {
-			return PostJson(service, uri, body, query)
+			return restful(c.context(), "POST", service, uri, nil, query, nil, body)
 		} else {
-			return GetJson(service, uri, body, query)
+			return restful(c.context(), "GET", service, uri, nil, query, nil, body)
 		}
 	case "restful":
 		method := "GET"
@@ -90,26 +104,26 @@ func (c *mginClient) Call(service string, uri string, params ...interface{}) (st
 			header = params[3]
 			body = params[4]
 		}
-		return RestfulWithHeader(method, service, uri, pathParams, queryParams, header, body)
+		return restful(c.context(), method, service, uri, pathParams, queryParams, header, body)
 	default:
 		return "", fmt.Errorf("微服务接口协议模式设置错误")
 	}
 }
 
 func (c *mginClient) CallForm(service string, uri string, params interface{}) (string, error) {
-	return CallWithHeader(service, uri, utils.AnyToMap(params), map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
+	return form(c.context(), "POST", service, uri, nil, nil, params, map[string]string{"Content-Type": "application/x-www-form-urlencoded"})
 }
 
 func (c *mginClient) CallJson(service string, uri string, method string, queryParams interface{}, jsonBody interface{}) (string, error) {
 	if method == "POST" {
-		return PostJson(service, uri, jsonBody, utils.AnyToMap(queryParams))
+		return restful(c.context(), "POST", service, uri, nil, queryParams, nil, jsonBody)
 	} else {
-		return GetJson(service, uri, jsonBody, utils.AnyToMap(queryParams))
+		return restful(c.context(), "GET", service, uri, nil, queryParams, nil, jsonBody)
 	}
 }
 
 func (c *mginClient) CallRestful(service string, uri string, method string, pathParams, queryParams, header, jsonBody interface{}) (string, error) {
-	return RestfulWithHeader(method, service, uri, pathParams, queryParams, header, jsonBody)
+	return restful(c.context(), method, service, uri, pathParams, queryParams, header, jsonBody)
 }
 
 func Get(service string, uri string, params interface{}) (string, error) {
@@ -117,286 +131,91 @@ func Get(service string, uri string, params interface{}) (string, error) {
 }
 
 func GetWithHeader(service string, uri string, params, header interface{}) (string, error) {
-	host, err := getHostFromCache(service)
-	group := "DEFAULT_GROUP"
-	if err != nil || host == "" {
-		discovery := config.Config.Discovery.Registry
-		if discovery == "" {
-			discovery = "nacos"
-		}
-		switch discovery {
-		case "nacos":
-			host, group = registry.Nacos.GetServiceURL(service)
-			if host != "" && !cache.OnGetCache("nacos").IsExist("nacos:subscribe:"+service) {
-				subscribeNacos(service, group)
-				cache.OnGetCache("nacos").Add("nacos:subscribe:"+service, "true", 0)
-			}
-			//case "consul":
-			//	host = registry.Nacos.GetConsulServiceURL(service)
-		}
-		if host != "" {
-			cache.OnGetCache("nacos").Add(service, host, 5*time.Minute)
-		} else {
-			return "", errors.New("微服务获取" + service + "服务主机IP端口失败")
-		}
-	}
-	url := host + uri
-	headers := trace.GetHeaders()
-	if header != nil {
-		h := utils.AnyToMap(header)
-		for k, v := range h {
-			if headers[k] == "" {
-				headers[k] = v
-			}
-		}
-	}
-	//通过X-Timeout来控制链路接口请求超时
-	timeout := 90 * time.Second
-	t := headers["X-Timeout"]
-	if t != "" {
-		ti, _ := strconv.Atoi(t)
-		if ti > 0 {
-			timeout = time.Duration(ti) * time.Second
-		}
-	}
-	logs.Debug("Nacos微服务请求:{}\n请求参数:{}\n请求头:{}", url, params, header)
-	resp, err := grequests.Get(url, &grequests.RequestOptions{
-		Params:             utils.AnyToMap(params),
-		Headers:            headers,
-		InsecureSkipVerify: true,
-		RequestTimeout:     timeout,
-	})
-	logs.Debug("Nacos微服务返回结果:{}", resp.String())
-	if err != nil && strings.Contains(err.Error(), "connection refused") {
-		cache.OnGetCache("nacos").Delete(service)
-		discovery := config.Config.Discovery.Registry
-		if discovery == "" {
-			discovery = "nacos"
-		}
-		switch discovery {
-		case "nacos":
-			host, group = registry.Nacos.GetServiceURL(service)
-			if host != "" && !cache.OnGetCache("nacos").IsExist("nacos:subscribe:"+service) {
-				subscribeNacos(service, group)
-				cache.OnGetCache("nacos").Add("nacos:subscribe:"+service, "true", 0)
-			}
-			//case "consul":
-			//	host = registry.Nacos.GetConsulServiceURL(service)
-		}
-		if host != "" {
-			cache.OnGetCache("nacos").Add(service, host, 5*time.Minute)
-		} else {
-			return "", errors.New("微服务获取" + service + "服务主机IP端口失败")
-		}
-		url = host + uri
-		resp, err = grequests.Get(url, &grequests.RequestOptions{
-			Params:             utils.AnyToMap(params),
-			Headers:            headers,
-			InsecureSkipVerify: true,
-		})
-		logs.Debug("Nacos微服务返回结果:{}", resp.String())
-		if err != nil {
-			if err != nil && strings.Contains(err.Error(), "dial tcp") {
-				return "", fmt.Errorf("Service unavailable")
-			}
-			return "", err
-		} else {
-			return resp.String(), nil
-		}
-	} else {
-		if err != nil && strings.Contains(err.Error(), "dial tcp") {
-			return "", fmt.Errorf("Service unavailable")
-		}
-		return resp.String(), err
-	}
+	return restful(context.Background(), "GET", service, uri, nil, params, header, nil)
 }
 
 // 微服务调用其他服务的接口,带header
 func CallWithHeader(service string, uri string, params, header interface{}) (string, error) {
-	host, err := getHostFromCache(service)
-	group := "DEFAULT_GROUP"
-	if err != nil || host == "" {
-		discovery := config.Config.Discovery.Registry
-		if discovery == "" {
-			discovery = "nacos"
-		}
-		switch discovery {
-		case "nacos":
-			host, group = registry.Nacos.GetServiceURL(service)
-			if host != "" && !cache.OnGetCache("nacos").IsExist("nacos:subscribe:"+service) {
-				subscribeNacos(service, group)
-				cache.OnGetCache("nacos").Add("nacos:subscribe:"+service, "true", 0)
-			}
-			//case "consul":
-			//	host = registry.Nacos.GetConsulServiceURL(service)
-		}
-		if host != "" {
-			cache.OnGetCache("nacos").Add(service, host, 5*time.Minute)
-		} else {
-			return "", errors.New("微服务获取" + service + "服务主机IP端口失败")
-		}
-	}
-	url := host + uri
-	headers := trace.GetHeaders()
-	if header != nil {
-		h := utils.AnyToMap(header)
-		for k, v := range h {
-			if headers[k] == "" {
-				headers[k] = v
-			}
-		}
-	}
-	//通过X-Timeout来控制链路接口请求超时
-	timeout := 90 * time.Second
-	t := headers["X-Timeout"]
-	if t != "" {
-		ti, _ := strconv.Atoi(t)
-		if ti > 0 {
-			timeout = time.Duration(ti) * time.Second
-		}
-	}
-	logs.Debug("Nacos微服务请求:{}\n请求参数:{}\n请求头:{}", url, params, header)
-	resp, err := grequests.Post(url, &grequests.RequestOptions{
-		Data:               utils.AnyToMap(params),
-		Headers:            headers,
-		InsecureSkipVerify: true,
-		RequestTimeout:     timeout,
-	})
-	logs.Debug("Nacos微服务返回结果:{}", resp.String())
-	if err != nil && strings.Contains(err.Error(), "connection refused") {
-		cache.OnGetCache("nacos").Delete(service)
-		discovery := config.Config.Discovery.Registry
-		if discovery == "" {
-			discovery = "nacos"
-		}
-		switch discovery {
-		case "nacos":
-			host, group = registry.Nacos.GetServiceURL(service)
-			if host != "" && !cache.OnGetCache("nacos").IsExist("nacos:subscribe:"+service) {
-				subscribeNacos(service, group)
-				cache.OnGetCache("nacos").Add("nacos:subscribe:"+service, "true", 0)
-			}
-			//case "consul":
-			//	host = registry.Nacos.GetConsulServiceURL(service)
-		}
-		if host != "" {
-			cache.OnGetCache("nacos").Add(service, host, 5*time.Minute)
-		} else {
-			return "", errors.New("微服务获取" + service + "服务主机IP端口失败")
-		}
-		url = host + uri
-		resp, err = grequests.Post(url, &grequests.RequestOptions{
-			Data:    utils.AnyToMap(params),
-			Headers: headers,
-		})
-		logs.Debug("Nacos微服务返回结果:{}", resp.String())
-		if err != nil {
-			if strings.Contains(err.Error(), "dial tcp") {
-				return "", fmt.Errorf("Service unavailable")
-			}
-			return "", err
-		} else {
-			return resp.String(), nil
-		}
-	} else {
-		if err != nil && strings.Contains(err.Error(), "dial tcp") {
-			return "", fmt.Errorf("Service unavailable")
-		}
-		return resp.String(), err
-	}
+	return form(context.Background(), "POST", service, uri, nil, nil, params, header)
 }
 
+// Deprecated: 请使用CallWithUploadFiles
 func CallWithFiles(service string, uri string, params interface{}, files []grequests.FileUpload) (string, error) {
 	return CallWithFilesHeader(service, uri, params, files, map[string]string{})
 }
 
-// 微服务调用其他服务的接口,带文件
+// 微服务调用其他服务的接口,带文件，文件内容以流式上传
+// Deprecated: 请使用CallWithUploadFilesHeader
 func CallWithFilesHeader(service string, uri string, params interface{}, files []grequests.FileUpload, header interface{}) (string, error) {
+	uploads := make([]UploadFile, 0, len(files))
+	for _, f := range files {
+		uploads = append(uploads, UploadFile{
+			FieldName: f.FieldName,
+			FileName:  f.FileName,
+			Reader:    f.FileContents,
+		})
+	}
+	return CallWithUploadFilesHeader(service, uri, params, uploads, header)
+}
+
+// 微服务调用其他服务的接口,带文件，文件内容以流式上传
+func CallWithUploadFiles(service string, uri string, params interface{}, files []UploadFile) (string, error) {
+	return CallWithUploadFilesHeader(service, uri, params, files, map[string]string{})
+}
+
+// 微服务调用其他服务的接口,带文件与header，文件内容以流式上传
+func CallWithUploadFilesHeader(service string, uri string, params interface{}, files []UploadFile, header interface{}) (string, error) {
+	return UploadWithHeader(context.Background(), service, uri, params, files, header, nil)
+}
+
+// getServiceHost 获取服务的主机地址，优先从缓存中获取
+func getServiceHost(service string) (string, error) {
 	host, err := getHostFromCache(service)
-	group := "DEFAULT_GROUP"
-	if err != nil || host == "" {
-		discovery := config.Config.Discovery.Registry
-		if discovery == "" {
-			discovery = "nacos"
-		}
-		switch discovery {
-		case "nacos":
-			host, group = registry.Nacos.GetServiceURL(service)
-			if host != "" && !cache.OnGetCache("nacos").IsExist("nacos:subscribe:"+service) {
-				subscribeNacos(service, group)
-				cache.OnGetCache("nacos").Add("nacos:subscribe:"+service, "true", 0)
-			}
-			//case "consul":
-			//	host = registry.Nacos.GetConsulServiceURL(service)
-		}
-		if host != "" {
-			cache.OnGetCache("nacos").Add(service, host, 5*time.Minute)
-		} else {
-			return "", errors.New("微服务获取" + service + "服务主机IP端口失败")
-		}
+	if err == nil && host != "" {
+		return host, nil
 	}
-	url := host + uri
-	headers := trace.GetHeaders()
-	if header != nil {
-		h := utils.AnyToMap(header)
-		for k, v := range h {
-			if headers[k] == "" {
-				headers[k] = v
-			}
+	return discoverServiceHost(service)
+}
+
+// refreshServiceHost 清除缓存并重新从注册中心获取服务地址
+func refreshServiceHost(service string) (string, error) {
+	cache.OnGetCache("nacos").Delete(service)
+	return discoverServiceHost(service)
+}
+
+func discoverServiceHost(service string) (string, error) {
+	hosts, group := []string{}, "DEFAULT_GROUP"
+	d := getDiscovery()
+	//缓存全部实例地址，对冲请求等需要多个实例
+	if d != nil {
+		if md, ok := d.(registry.MultiDiscovery); ok {
+			hosts, group = md.GetServiceURLs(service)
+		} else if host, g := d.GetServiceURL(service); host != "" {
+			hosts, group = []string{host}, g
 		}
 	}
-	delete(headers, "Content-Type")
-	logs.Debug("Nacos微服务请求:{}\n请求参数:{}\n请求头:{}", url, params, header)
-	resp, err := grequests.Post(url, &grequests.RequestOptions{
-		Data:               utils.AnyToMap(params),
-		Files:              files,
-		Headers:            headers,
-		InsecureSkipVerify: true,
-	})
-	logs.Debug("Nacos微服务返回结果:{}", resp.String())
-	if err != nil && strings.Contains(err.Error(), "connection refused") {
-		cache.OnGetCache("nacos").Delete(service)
-		discovery := config.Config.Discovery.Registry
-		if discovery == "" {
-			discovery = "nacos"
-		}
-		switch discovery {
-		case "nacos":
-			host, group = registry.Nacos.GetServiceURL(service)
-			if host != "" && !cache.OnGetCache("nacos").IsExist("nacos:subscribe:"+service) {
-				subscribeNacos(service, group)
-				cache.OnGetCache("nacos").Add("nacos:subscribe:"+service, "true", 0)
-			}
-			//case "consul":
-			//	host = registry.Nacos.GetConsulServiceURL(service)
-		}
-		if host != "" {
-			cache.OnGetCache("nacos").Add(service, host, 5*time.Minute)
-		} else {
-			return "", errors.New("微服务获取" + service + "服务主机IP端口失败")
-		}
-		url = host + uri
-		resp, err = grequests.Post(url, &grequests.RequestOptions{
-			Data:               utils.AnyToMap(params),
-			Files:              files,
-			Headers:            headers,
-			InsecureSkipVerify: true,
-		})
-		logs.Debug("Nacos微服务返回结果:{}", resp.String())
-		if err != nil {
-			if strings.Contains(err.Error(), "dial tcp") {
-				return "", fmt.Errorf("Service unavailable")
-			}
-			return "", err
-		} else {
-			return resp.String(), nil
-		}
-	} else {
-		if err != nil && strings.Contains(err.Error(), "dial tcp") {
-			return "", fmt.Errorf("Service unavailable")
-		}
-		return resp.String(), err
+	//仅Nacos服务发现订阅服务变更
+	if d == registry.Discovery(registry.Nacos) && len(hosts) > 0 && !cache.OnGetCache("nacos").IsExist("nacos:subscribe:"+service) {
+		subscribeNacos(service, group)
+		cache.OnGetCache("nacos").Add("nacos:subscribe:"+service, "true", 0)
+	}
+	if len(hosts) == 0 {
+		return "", errors.New("微服务获取" + service + "服务主机IP端口失败")
+	}
+	cache.OnGetCache("nacos").Add(service, strings.Join(hosts, ","), 5*time.Minute)
+	return hosts[rand.Intn(len(hosts))], nil
+}
+
+// getServiceHosts 获取服务的全部实例地址
+func getServiceHosts(service string) ([]string, error) {
+	if _, err := getServiceHost(service); err != nil {
+		return nil, err
+	}
+	h, _ := cache.OnGetCache("nacos").Value(service)
+	if h == nil {
+		return nil, errors.New("无此服务缓存")
 	}
+	return strings.Split(h.(string), ","), nil
 }
 
 func getHostFromCache(serviceName string) (string, error) {
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"encoding/json"
+	"github.com/maczh/mgin/cache"
+	"github.com/maczh/mgin/db"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/scope"
+	"github.com/maczh/mgin/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 正在进行中的相同请求，用于合并并发的相同调用
+type inflightCall struct {
+	wg   sync.WaitGroup
+	resp *callResponse
+	err  error
+}
+
+var inflight = struct {
+	sync.Mutex
+	calls map[string]*inflightCall
+}{calls: make(map[string]*inflightCall)}
+
+// cacheable 是否缓存调用结果，仅GET请求且在go.client.cache.enable或服务级别开启时缓存
+// 可通过cache.uris限定只缓存指定的接口
+func (o *callOptions) cacheable() bool {
+	if o.method != "GET" || !clientConfigBool(o.service, "cache.enable", false) {
+		return false
+	}
+	uris := clientConfigString(o.service, "cache.uris", "")
+	if uris == "" {
+		return true
+	}
+	for _, uri := range strings.Split(uris, ",") {
+		if strings.TrimSpace(uri) == o.uri {
+			return true
+		}
+	}
+	return false
+}
+
+// 默认参与缓存键的请求头，不同用户或语言的调用结果分别缓存
+const defaultVaryHeaders = "Authorization,Cookie,X-Token,X-Lang"
+
+// cacheKey 缓存键包含服务、接口、参数、请求体与cache.vary中的请求头
+func (o *callOptions) cacheKey() string {
+	query := url.Values{}
+	for k, v := range o.query {
+		query.Set(k, v)
+	}
+	vary := url.Values{}
+	for _, name := range strings.Split(clientConfigString(o.service, "cache.vary", defaultVaryHeaders), ",") {
+		if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+			vary.Set(name, o.header(name))
+		}
+	}
+	body, _, _ := o.encodeBody()
+	return "mgin:client:cache:" + utils.MD5Encode(o.service+"\n"+o.uri+"\n"+query.Encode()+"\n"+string(body)+"\n"+vary.Encode())
+}
+
+// header 获取调用的请求头，不区分大小写
+func (o *callOptions) header(name string) string {
+	for k, v := range o.headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// cachedExecute 先查缓存，未命中时合并并发的相同请求，只向上游发送一次
+func cachedExecute(o *callOptions) (*callResponse, error) {
+	key := o.cacheKey()
+	if body, ok := getCachedResponse(o.service, key); ok {
+		logs.WithContext(o.context()).Debug("微服务{}{}命中缓存", o.service, o.uri)
+		return &callResponse{statusCode: http.StatusOK, body: body}, nil
+	}
+	return coalesce(key, func() (*callResponse, error) {
+		//合并的调用由多个请求共享，不随第一个请求取消
+		shared := *o
+		shared.ctx = scope.Detach(o.context())
+		resp, err := executeCall(&shared)
+		if err == nil && resp.statusCode == http.StatusOK && succeeded(resp.body) {
+			if ttl := cacheTTL(o.service, resp.header); ttl > 0 {
+				setCachedResponse(o.service, key, resp.body, ttl)
+			}
+		}
+		return resp, err
+	})
+}
+
+// succeeded 返回结果是否为成功的业务结果(status为1)，业务失败与故障注入的结果不缓存
+func succeeded(body string) bool {
+	var result struct {
+		Status int `json:"status"`
+	}
+	return json.Unmarshal([]byte(body), &result) == nil && result.Status == 1
+}
+
+func coalesce(key string, fn func() (*callResponse, error)) (*callResponse, error) {
+	inflight.Lock()
+	if c, ok := inflight.calls[key]; ok {
+		inflight.Unlock()
+		c.wg.Wait()
+		return c.resp, c.err
+	}
+	c := new(inflightCall)
+	c.wg.Add(1)
+	inflight.calls[key] = c
+	inflight.Unlock()
+
+	c.resp, c.err = fn()
+	c.wg.Done()
+
+	inflight.Lock()
+	delete(inflight.calls, key)
+	inflight.Unlock()
+	return c.resp, c.err
+}
+
+// cacheTTL 缓存时间，优先使用cache.ttl配置，否则使用响应头Cache-Control中的max-age
+// 响应头为no-store、no-cache或private时不缓存
+func cacheTTL(service string, header http.Header) time.Duration {
+	maxAge := 0
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store", directive == "no-cache", directive == "private":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			maxAge, _ = strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		}
+	}
+	ttl := clientConfigInt(service, "cache.ttl", 0)
+	if ttl <= 0 {
+		ttl = maxAge
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+// 缓存存放在本地缓存或Redis中，由cache.store配置，默认local
+func useRedisCache(service string) bool {
+	return clientConfigString(service, "cache.store", "local") == "redis"
+}
+
+func redisCacheDb(service string) []string {
+	if db.Redis.IsMultiDB() {
+		return []string{clientConfigString(service, "cache.redisDb", "")}
+	}
+	return nil
+}
+
+func getCachedResponse(service, key string) (string, bool) {
+	if useRedisCache(service) {
+		conn, err := db.Redis.GetConnection(redisCacheDb(service)...)
+		if err != nil {
+			logs.Error("微服务调用缓存Redis连接失败:{}", err.Error())
+			return "", false
+		}
+		body, err := conn.Get(key).Result()
+		return body, err == nil
+	}
+	body, ok := cache.OnGetCache("client").Value(key)
+	if !ok {
+		return "", false
+	}
+	return body.(string), true
+}
+
+func setCachedResponse(service, key, body string, ttl time.Duration) {
+	if useRedisCache(service) {
+		conn, err := db.Redis.GetConnection(redisCacheDb(service)...)
+		if err != nil {
+			logs.Error("微服务调用缓存Redis连接失败:{}", err.Error())
+			return
+		}
+		if err = conn.Set(key, body, ttl).Err(); err != nil {
+			logs.Error("微服务调用结果写入Redis缓存失败:{}", err.Error())
+		}
+		return
+	}
+	cache.OnGetCache("client").Add(key, body, ttl)
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/mask"
+	"github.com/maczh/mgin/utils"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+)
+
+// UploadFile 流式上传的文件，Size未知时填0
+// Reader实现了io.Closer时上传完成后自动关闭
+type UploadFile struct {
+	FieldName string
+	FileName  string
+	Reader    io.Reader
+	Size      int64
+}
+
+// ProgressFunc 传输进度回调，transferred为已传输字节数，total未知时为-1
+type ProgressFunc func(transferred, total int64)
+
+type progressWriter struct {
+	w           io.Writer
+	total       int64
+	transferred int64
+	progress    ProgressFunc
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.transferred += int64(n)
+	if p.progress != nil {
+		p.progress(p.transferred, p.total)
+	}
+	return n, err
+}
+
+// Upload 以multipart方式流式上传文件到其他微服务，文件内容不在内存中缓存，ctx为nil时使用context.Background()
+func Upload(ctx context.Context, service, uri string, params interface{}, files []UploadFile, progress ProgressFunc) (string, error) {
+	return UploadWithHeader(ctx, service, uri, params, files, map[string]string{}, progress)
+}
+
+// UploadWithHeader 以multipart方式流式上传文件到其他微服务,带header
+// 开启请求签名时请求体不参与签名
+func UploadWithHeader(ctx context.Context, service, uri string, params interface{}, files []UploadFile, header interface{}, progress ProgressFunc) (string, error) {
+	o := &callOptions{
+		ctx:     ctx,
+		method:  "POST",
+		service: service,
+		uri:     uri,
+		headers: callHeaders(ctx, header),
+		form:    utils.AnyToMap(params),
+	}
+	host, err := getServiceHost(service)
+	if err != nil {
+		return "", err
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	req, err := o.newRequest(o.context(), host, pr, utils.UnsignedPayload, mw.FormDataContentType())
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, o.form, files, progress))
+	}()
+	logs.WithContext(o.context()).Debug("Nacos微服务上传:{}\n请求参数:{}\n请求头:{}", host+uri, mask.Value(o.form), mask.Headers(o.headers))
+	resp, err := o.do(req)
+	if err != nil {
+		pr.CloseWithError(err)
+		return "", callError(err)
+	}
+	//故障注入等未读取请求体的返回，关闭后写入协程随之退出
+	defer pr.Close()
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	logs.WithContext(o.context()).Debug("Nacos微服务返回结果:{}", mask.JSON(string(data)))
+	return string(data), nil
+}
+
+func writeMultipart(mw *multipart.Writer, form map[string]string, files []UploadFile, progress ProgressFunc) error {
+	for k, v := range form {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	var total int64
+	for _, f := range files {
+		if f.Size <= 0 {
+			total = -1
+			break
+		}
+		total += f.Size
+	}
+	pw := &progressWriter{total: total, progress: progress}
+	for i, f := range files {
+		fieldName := f.FieldName
+		if fieldName == "" {
+			fieldName = "file" + strconv.Itoa(i+1)
+		}
+		part, err := mw.CreateFormFile(fieldName, f.FileName)
+		if err != nil {
+			return err
+		}
+		pw.w = part
+		_, err = io.Copy(pw, f.Reader)
+		if c, ok := f.Reader.(io.Closer); ok {
+			c.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// Download 从其他微服务流式下载内容写入w，返回写入的字节数，ctx为nil时使用context.Background()
+func Download(ctx context.Context, service, uri string, w io.Writer, params interface{}, progress ProgressFunc) (int64, error) {
+	return DownloadWithHeader(ctx, service, uri, w, params, map[string]string{}, progress)
+}
+
+// DownloadWithHeader 从其他微服务流式下载内容写入w,带header，非200的返回视为失败
+func DownloadWithHeader(ctx context.Context, service, uri string, w io.Writer, params, header interface{}, progress ProgressFunc) (int64, error) {
+	o := &callOptions{
+		ctx:     ctx,
+		method:  "GET",
+		service: service,
+		uri:     uri,
+		headers: callHeaders(ctx, header),
+		query:   utils.AnyToMap(params),
+	}
+	host, err := getServiceHost(service)
+	if err != nil {
+		return 0, err
+	}
+	req, err := o.newRequest(o.context(), host, nil, utils.Sha256(""), "")
+	if err != nil {
+		return 0, err
+	}
+	logs.WithContext(o.context()).Debug("Nacos微服务下载:{}\n请求参数:{}\n请求头:{}", host+uri, mask.Value(o.query), mask.Headers(o.headers))
+	resp, err := o.do(req)
+	if err != nil {
+		return 0, callError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("下载失败,状态码:%d,返回:%s", resp.StatusCode, string(data))
+	}
+	return io.Copy(&progressWriter{w: w, total: resp.ContentLength, progress: progress}, resp.Body)
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/registry"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// transportOptions 微服务调用的连接池与TLS配置
+// 全局配置位于go.client下，可在go.client.services.<服务名>下按服务覆盖
+type transportOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration
+	TLSHandshakeTimeout time.Duration
+	HTTP2               bool
+	InsecureSkipVerify  bool
+	CAFile              string
+	CertFile            string
+	KeyFile             string
+	ServerName          string
+}
+
+// 每个服务一个共享的http.Client
+var httpClients sync.Map
+
+func loadTransportOptions(service string) transportOptions {
+	//开启双向认证时，默认使用本服务的证书作为客户端证书
+	ca, cert, key := config.Current().App.CA, "", ""
+	if config.Current().App.MTLS {
+		cert, key = config.Current().App.Cert, config.Current().App.Key
+	}
+	return transportOptions{
+		MaxIdleConns:        clientConfigInt(service, "maxIdleConns", 100),
+		MaxIdleConnsPerHost: clientConfigInt(service, "maxIdleConnsPerHost", 10),
+		MaxConnsPerHost:     clientConfigInt(service, "maxConnsPerHost", 0),
+		IdleConnTimeout:     time.Duration(clientConfigInt(service, "idleTimeout", 90)) * time.Second,
+		DialTimeout:         time.Duration(clientConfigInt(service, "dialTimeout", 5)) * time.Second,
+		KeepAlive:           time.Duration(clientConfigInt(service, "keepAlive", 30)) * time.Second,
+		TLSHandshakeTimeout: time.Duration(clientConfigInt(service, "tlsHandshakeTimeout", 10)) * time.Second,
+		HTTP2:               clientConfigBool(service, "http2", true),
+		InsecureSkipVerify:  clientConfigBool(service, "tls.insecureSkipVerify", false),
+		CAFile:              config.Config.AbsPath(clientConfigString(service, "tls.ca", ca)),
+		CertFile:            config.Config.AbsPath(clientConfigString(service, "tls.cert", cert)),
+		KeyFile:             config.Config.AbsPath(clientConfigString(service, "tls.key", key)),
+		ServerName:          clientConfigString(service, "tls.serverName", ""),
+	}
+}
+
+func clientConfigKey(service, name string) string {
+	key := fmt.Sprintf("go.client.services.%s.%s", service, name)
+	if service != "" && config.Config.Exists(key) {
+		return key
+	}
+	key = "go.client." + name
+	if config.Config.Exists(key) {
+		return key
+	}
+	return ""
+}
+
+func clientConfigInt(service, name string, def int) int {
+	key := clientConfigKey(service, name)
+	if key == "" {
+		return def
+	}
+	return config.Config.GetConfigInt(key)
+}
+
+func clientConfigBool(service, name string, def bool) bool {
+	key := clientConfigKey(service, name)
+	if key == "" {
+		return def
+	}
+	return config.Config.GetConfigBool(key)
+}
+
+func clientConfigString(service, name string, def string) string {
+	key := clientConfigKey(service, name)
+	if key == "" {
+		return def
+	}
+	return config.Config.GetConfigString(key)
+}
+
+func newTLSConfig(opts transportOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+		ServerName:         opts.ServerName,
+	}
+	if opts.CAFile != "" {
+		ca, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书%s失败:%s", opts.CAFile, err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("CA证书" + opts.CAFile + "格式错误")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.CertFile != "" && opts.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败:%s", err.Error())
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func newTransport(opts transportOptions) (*http.Transport, error) {
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   opts.DialTimeout,
+		KeepAlive: opts.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        opts.MaxIdleConns,
+		MaxIdleConnsPerHost: opts.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     opts.MaxConnsPerHost,
+		IdleConnTimeout:     opts.IdleConnTimeout,
+		TLSHandshakeTimeout: opts.TLSHandshakeTimeout,
+		TLSClientConfig:     tlsConfig,
+		ForceAttemptHTTP2:   opts.HTTP2,
+	}, nil
+}
+
+// 替换所有服务调用的RoundTripper与服务发现，用于测试
+var (
+	overrideLock      sync.RWMutex
+	transportOverride http.RoundTripper
+	discoveryOverride registry.Discovery
+)
+
+// SetTransport 替换所有微服务调用使用的http.RoundTripper，传nil时恢复使用连接池，主要用于测试
+func SetTransport(rt http.RoundTripper) {
+	overrideLock.Lock()
+	defer overrideLock.Unlock()
+	transportOverride = rt
+}
+
+// SetDiscovery 替换所有微服务调用使用的服务发现，优先于go.discovery.registry配置，传nil时恢复，主要用于测试
+func SetDiscovery(d registry.Discovery) {
+	overrideLock.Lock()
+	defer overrideLock.Unlock()
+	discoveryOverride = d
+}
+
+// getDiscovery 获取服务发现，未替换时使用go.discovery.registry配置的注册中心，默认nacos
+func getDiscovery() registry.Discovery {
+	overrideLock.RLock()
+	d := discoveryOverride
+	overrideLock.RUnlock()
+	if d != nil {
+		return d
+	}
+	name := config.Current().Discovery.Registry
+	if name == "" {
+		name = "nacos"
+	}
+	return registry.Get(name)
+}
+
+// getHttpClient 获取服务对应的共享http.Client，首次调用时按配置创建
+func getHttpClient(service string) *http.Client {
+	overrideLock.RLock()
+	rt := transportOverride
+	overrideLock.RUnlock()
+	if rt != nil {
+		return &http.Client{Transport: rt}
+	}
+	if c, ok := httpClients.Load(service); ok {
+		return c.(*http.Client)
+	}
+	transport, err := newTransport(loadTransportOptions(service))
+	if err != nil {
+		logs.Error("微服务{}连接池配置错误:{}", service, err.Error())
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	c, _ := httpClients.LoadOrStore(service, &http.Client{Transport: transport})
+	return c.(*http.Client)
+}
+
+// CloseIdleConnections 关闭所有服务连接池中的空闲连接
+func CloseIdleConnections() {
+	httpClients.Range(func(key, value interface{}) bool {
+		value.(*http.Client).CloseIdleConnections()
+		return true
+	})
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"sort"
+	"strings"
+)
+
+// generator 生成客户端代码
+type generator struct {
+	service string
+	name    string
+	imports map[string]string
+	buf     bytes.Buffer
+}
+
+// generate 生成客户端源码，按gofmt格式化
+func generate(pkg, service, name string, a *api) ([]byte, error) {
+	g := &generator{
+		service: service,
+		name:    name,
+		imports: map[string]string{"context": "context", "github.com/maczh/mgin/client": "client"},
+	}
+	for p, n := range a.imports {
+		g.imports[p] = n
+	}
+	endpoints := a.endpoints
+	g.printf("type %sClient struct{}\n\n", unexportName(name))
+	g.printf("// %s %s服务的客户端\n", name, service)
+	g.printf("var %s = &%sClient{}\n\n", name, unexportName(name))
+	for _, t := range a.types {
+		g.printf("// %s 对应服务端的%s\n", t.name, t.origin)
+		if t.alias {
+			g.printf("type %s = %s\n\n", t.name, t.src)
+		} else {
+			g.printf("type %s %s\n\n", t.name, t.src)
+		}
+	}
+	for _, ep := range endpoints {
+		g.endpoint(ep)
+	}
+	g.printf("// %sHeader 合并调用时传入的header\n", unexportName(name))
+	g.printf("func %sHeader(header []map[string]string) map[string]string {\n", unexportName(name))
+	g.printf("headers := make(map[string]string)\n")
+	g.printf("for _, h := range header {\nfor k, v := range h {\nheaders[k] = v\n}\n}\n")
+	g.printf("return headers\n}\n")
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by mgin-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	paths := make([]string, 0, len(g.imports))
+	for p := range g.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	fmt.Fprintf(&out, "import (\n")
+	for _, p := range paths {
+		if n := g.imports[p]; n != path.Base(p) {
+			fmt.Fprintf(&out, "%s %q\n", n, p)
+		} else {
+			fmt.Fprintf(&out, "%q\n", p)
+		}
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	fmt.Fprintf(&out, "const Service%s = %q\n\n", name, service)
+	fmt.Fprintf(&out, "const (\n")
+	for _, ep := range endpoints {
+		fmt.Fprintf(&out, "Uri%s%s = %q\n", name, ep.name, ep.path)
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	out.Write(g.buf.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return out.Bytes(), fmt.Errorf("格式化生成代码失败:%v", err)
+	}
+	return src, nil
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) use(p string) {
+	g.imports[p] = path.Base(p)
+}
+
+// endpoint 生成一个接口的请求结构体、返回结构体与调用方法
+func (g *generator) endpoint(ep *endpoint) {
+	uri := "Uri" + g.name + ep.name
+	summary := ep.summary
+	if summary == "" {
+		summary = "调用" + ep.path
+	}
+	if !ep.typed {
+		g.untyped(ep, uri, summary)
+		return
+	}
+
+	if len(ep.params) > 0 {
+		g.printf("// %sRequest %s的请求参数\n", ep.name, ep.name)
+		g.printf("type %sRequest struct {\n", ep.name)
+		for _, p := range ep.params {
+			g.printf("%s %s `json:\"%s\"`", exportName(p.name), p.typ, p.name)
+			if p.comment != "" {
+				g.printf(" //%s", p.comment)
+			}
+			g.printf("\n")
+		}
+		g.printf("}\n\n")
+	}
+	resultType := "models.Result"
+	if ep.data != "" {
+		resultType = ep.name + "Result"
+		g.use(modelsPath)
+		g.printf("// %s %s的返回结果\n", resultType, ep.name)
+		g.printf("type %s struct {\n", resultType)
+		g.printf("Status int `json:\"status\"`\n")
+		g.printf("Msg string `json:\"msg\"`\n")
+		g.printf("Data %s `json:\"data\"`\n", ep.data)
+		g.printf("Page *models.ResultPage `json:\"page\"`\n")
+		g.printf("}\n\n")
+	} else if ep.raw != "" {
+		resultType = ep.raw
+	} else {
+		g.use(modelsPath)
+	}
+
+	args := []string{"ctx context.Context"}
+	if len(ep.params) > 0 {
+		args = append(args, "req "+ep.name+"Request")
+	}
+	if ep.body != "" {
+		args = append(args, "body "+ep.body)
+	}
+	args = append(args, "header ...map[string]string")
+	g.printf("// %s %s\n// %s %s\n", ep.name, summary, ep.method, ep.path)
+	if ep.raw != "" {
+		g.printf("func (c *%sClient) %s(%s) (%s, error) {\n", unexportName(g.name), ep.name, strings.Join(args, ", "), resultType)
+	} else {
+		g.printf("func (c *%sClient) %s(%s) %s {\n", unexportName(g.name), ep.name, strings.Join(args, ", "), resultType)
+	}
+	g.printf("headers := %sHeader(header)\n", unexportName(g.name))
+	maps := map[string]string{"path": "nil", "query": "nil", "formData": "nil"}
+	for _, in := range []string{"path", "query", "formData"} {
+		if g.hasParam(ep, in) {
+			maps[in] = in
+			g.printf("%s := make(map[string]string)\n", in)
+		}
+	}
+	for _, p := range ep.params {
+		target := p.in + "[\"" + p.name + "\"]"
+		if p.in == "header" {
+			target = "headers[\"" + p.name + "\"]"
+		} else if _, ok := maps[p.in]; !ok {
+			continue
+		}
+		field := "req." + exportName(p.name)
+		if cond := nonZero(field, p.typ); p.required || p.in == "path" || cond == "" {
+			g.printf("%s = %s\n", target, g.toString(field, p.typ))
+		} else {
+			g.printf("if %s {\n%s = %s\n}\n", cond, target, g.toString(field, p.typ))
+		}
+	}
+	switch {
+	case ep.body != "":
+		g.printf("resp, err := client.RestfulWithContext(ctx, %q, Service%s, %s, %s, %s, headers, body)\n", ep.method, g.name, uri, maps["path"], maps["query"])
+	case maps["formData"] != "nil":
+		g.printf("resp, err := client.FormWithContext(ctx, %q, Service%s, %s, %s, %s, formData, headers)\n", ep.method, g.name, uri, maps["path"], maps["query"])
+	default:
+		g.printf("resp, err := client.RestfulWithContext(ctx, %q, Service%s, %s, %s, %s, headers, nil)\n", ep.method, g.name, uri, maps["path"], maps["query"])
+	}
+	switch {
+	case ep.raw == "string":
+		g.printf("return resp, err\n}\n\n")
+	case ep.raw != "":
+		g.use("encoding/json")
+		g.printf("var result %s\n", resultType)
+		g.printf("if err != nil {\nreturn result, err\n}\n")
+		g.printf("err = json.Unmarshal([]byte(resp), &result)\n")
+		g.printf("return result, err\n}\n\n")
+	case ep.data != "":
+		g.use("github.com/maczh/mgin/utils")
+		g.printf("if err != nil {\nreturn %s{Status: client.ErrorCode(err), Msg: err.Error()}\n}\n", resultType)
+		g.printf("var result %s\n", resultType)
+		g.printf("utils.FromJSON(resp, &result)\n")
+		g.printf("return result\n}\n\n")
+	default:
+		g.resultBody()
+	}
+}
+
+// untyped 没有swag注释的接口，参数为map[string]string，返回models.Result
+func (g *generator) untyped(ep *endpoint, uri, summary string) {
+	g.use(modelsPath)
+	g.printf("// %s %s\n// %s %s\n", ep.name, summary, ep.method, ep.path)
+	g.printf("func (c *%sClient) %s(ctx context.Context, params map[string]string, header ...map[string]string) models.Result {\n", unexportName(g.name), ep.name)
+	pathParams := "nil"
+	if strings.Contains(ep.path, "{") {
+		pathParams = "params"
+	}
+	switch ep.method {
+	case "GET", "DELETE", "HEAD", "OPTIONS":
+		g.printf("resp, err := client.RestfulWithContext(ctx, %q, Service%s, %s, %s, params, %sHeader(header), nil)\n", ep.method, g.name, uri, pathParams, unexportName(g.name))
+	default:
+		g.printf("resp, err := client.FormWithContext(ctx, %q, Service%s, %s, %s, nil, params, %sHeader(header))\n", ep.method, g.name, uri, pathParams, unexportName(g.name))
+	}
+	g.resultBody()
+}
+
+func (g *generator) resultBody() {
+	g.use("github.com/maczh/mgin/utils")
+	g.printf("if err != nil {\nreturn models.Error(client.ErrorCode(err), err.Error())\n}\n")
+	g.printf("var result models.Result\n")
+	g.printf("utils.FromJSON(resp, &result)\n")
+	g.printf("return result\n}\n\n")
+}
+
+func (g *generator) hasParam(ep *endpoint, in string) bool {
+	for _, p := range ep.params {
+		if p.in == in {
+			return true
+		}
+	}
+	return false
+}
+
+// toString 生成将参数转换为字符串的表达式，数组以逗号分隔
+func (g *generator) toString(field, typ string) string {
+	switch {
+	case typ == "string":
+		return field
+	case typ == "[]string":
+		g.use("strings")
+		return "strings.Join(" + field + ", \",\")"
+	case strings.HasPrefix(typ, "[]"):
+		g.use("strings")
+		g.use("fmt")
+		return "strings.Trim(strings.Join(strings.Fields(fmt.Sprint(" + field + ")), \",\"), \"[]\")"
+	default:
+		g.use("fmt")
+		return "fmt.Sprint(" + field + ")"
+	}
+}
+
+// nonZero 可选参数非零值时才传递，无法判断零值的类型返回空
+func nonZero(field, typ string) string {
+	switch {
+	case typ == "string":
+		return field + " != \"\""
+	case typ == "bool":
+		return field
+	case strings.HasPrefix(typ, "[]"):
+		return "len(" + field + ") > 0"
+	case typ == "interface{}":
+		return field + " != nil"
+	case strings.Contains(typ, "."):
+		return ""
+	default:
+		return field + " != 0"
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	a, err := parseDir("testdata/server", "Demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate("democlient", "demo-server", "Demo", a)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	if _, err = parser.ParseFile(token.NewFileSet(), "demo.go", src, 0); err != nil {
+		t.Fatalf("生成的代码无法解析:%v", err)
+	}
+	checkGolden(t, "gen.golden", src)
+}
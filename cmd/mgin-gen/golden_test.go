@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "更新testdata下的golden文件")
+
+// checkGolden 与testdata下的golden文件比较，-update时改为写入
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(file, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("读取%s失败:%v，可使用-update生成", file, err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s不一致，使用-update更新\n--- got\n%s\n--- want\n%s", file, got, want)
+	}
+}
@@ -0,0 +1,90 @@
+// mgin-gen 根据服务端的路由定义与swag注释生成类型化的微服务客户端代码
+//
+// 用法:
+//
+//	go run github.com/maczh/mgin/cmd/mgin-gen -dir ../mgin-server -service mgin-server -pkg mgclient -out mgin_server.go
+//
+// 带@Router注释的接口按@Param与@Success生成请求结构体与返回结构体，用到的服务端类型复制到生成代码中，
+// 生成的方法第一个参数为ctx，可直接传入*gin.Context以透传请求头与链路信息，
+// 只在路由中定义而没有注释的接口生成以map[string]string为参数、返回models.Result的方法
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "服务端工程源码目录")
+	service := flag.String("service", "", "服务端在注册中心的服务名")
+	pkg := flag.String("pkg", "", "生成代码的包名，默认为输出文件所在目录名")
+	out := flag.String("out", "", "输出文件，默认输出到标准输出")
+	name := flag.String("name", "", "客户端变量名，默认由服务名转换")
+	flag.Parse()
+	if *service == "" {
+		fmt.Fprintln(os.Stderr, "mgin-gen: 必须指定-service")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *pkg == "" {
+		*pkg = "mgclient"
+		if *out != "" {
+			if abs, err := filepath.Abs(*out); err == nil {
+				*pkg = filepath.Base(filepath.Dir(abs))
+			}
+		}
+	}
+	if *name == "" {
+		*name = exportName(*service)
+	}
+
+	a, err := parseDir(*dir, *name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mgin-gen:", err)
+		os.Exit(1)
+	}
+	if len(a.endpoints) == 0 {
+		fmt.Fprintln(os.Stderr, "mgin-gen: 未找到任何路由定义或@Router注释")
+		os.Exit(1)
+	}
+	src, err := generate(*pkg, *service, *name, a)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "mgin-gen:", err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err = ioutil.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "mgin-gen:", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "mgin-gen: 已生成%d个接口到%s\n", len(a.endpoints), *out)
+}
+
+// exportName 将mgin-server、user_id、jobTitle等名称转换为导出的驼峰名称
+func exportName(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	var b strings.Builder
+	for _, w := range words {
+		b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+	}
+	name := b.String()
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "X" + name
+	}
+	return name
+}
+
+// unexportName 首字母小写
+func unexportName(s string) string {
+	name := exportName(s)
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,607 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mgin通用返回结果所在的包
+const modelsPath = "github.com/maczh/mgin/models"
+
+// param 接口参数，in为path、query、formData或header
+type param struct {
+	name     string
+	in       string
+	typ      string
+	required bool
+	comment  string
+}
+
+// endpoint 一个服务端接口
+type endpoint struct {
+	name    string
+	summary string
+	method  string
+	path    string
+	typed   bool
+	params  []param
+	body    string
+	data    string
+	raw     string
+}
+
+// api 解析服务端源码的结果
+type api struct {
+	endpoints []*endpoint
+	imports   map[string]string //生成代码需要导入的包，导入路径→包名
+	types     []*localType      //需复制到生成代码中的服务端类型，按名称排序
+}
+
+// typeDecl 服务端源码中的类型定义
+type typeDecl struct {
+	spec *ast.TypeSpec
+	file *resolver
+}
+
+// localType 复制到生成代码中的服务端类型，客户端不再依赖服务端的包
+type localType struct {
+	name   string
+	origin string
+	alias  bool
+	src    string
+}
+
+// typeTable 服务端的类型定义与已复制的类型，类型以包路径.类型名标识
+type typeTable struct {
+	prefix string
+	decls  map[string]*typeDecl
+	local  map[string]*localType
+	names  map[string]bool
+	used   map[string]string
+}
+
+// resolver 将swag注释与服务端类型定义中的类型转换为生成代码中的Go类型
+type resolver struct {
+	imports map[string]string
+	pkgName string
+	pkgKey  string
+	types   *typeTable
+}
+
+var (
+	paramRegexp   = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\S+)\s+(\S+)\s*(?:"([^"]*)")?`)
+	successRegexp = regexp.MustCompile(`^(\d+)\s+\{(\w+)\}\s+(\S+)`)
+	routerRegexp  = regexp.MustCompile(`^(\S+)\s+\[(\w+)\]`)
+	ginPathRegexp = regexp.MustCompile(`[:*](\w+)`)
+	versionRegexp = regexp.MustCompile(`^v\d+$`)
+	routeMethods  = map[string]bool{"GET": true, "POST": true, "PUT": true, "DELETE": true, "PATCH": true, "HEAD": true, "OPTIONS": true}
+	basicTypes    = map[string]bool{"string": true, "bool": true, "byte": true, "rune": true, "error": true, "any": true,
+		"int": true, "int8": true, "int16": true, "int32": true, "int64": true, "uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+		"float32": true, "float64": true, "complex64": true, "complex128": true, "uintptr": true}
+)
+
+// parseDir 扫描目录下全部Go源文件，收集@Router注释的接口与路由定义中的接口
+// 接口用到的服务端类型以prefix加原类型名复制到生成代码中
+func parseDir(dir, prefix string) (*api, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	modRoot, modPath := findModule(dir)
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var paths []string
+	err = filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if name := info.Name(); p != dir && (name == "vendor" || name == "docs" || strings.HasPrefix(name, ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, p, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	basePath := ""
+	for _, f := range files {
+		for _, cg := range f.Comments {
+			for _, line := range annotationLines(cg) {
+				if strings.HasPrefix(line, "@BasePath ") || strings.HasPrefix(line, "@BasePath\t") {
+					basePath = strings.TrimRight(strings.TrimSpace(strings.TrimPrefix(line, "@BasePath")), "/")
+				}
+			}
+		}
+	}
+
+	types := &typeTable{
+		prefix: prefix,
+		decls:  make(map[string]*typeDecl),
+		local:  make(map[string]*localType),
+		names:  make(map[string]bool),
+		used:   make(map[string]string),
+	}
+	resolvers := make([]*resolver, len(files))
+	for i, f := range files {
+		r := &resolver{
+			imports: fileImports(f),
+			pkgName: f.Name.Name,
+			pkgKey:  filepath.Dir(paths[i]),
+			types:   types,
+		}
+		if modPath != "" {
+			if rel, err := filepath.Rel(modRoot, filepath.Dir(paths[i])); err == nil {
+				r.pkgKey = path.Join(modPath, filepath.ToSlash(rel))
+			}
+		}
+		resolvers[i] = r
+		for _, decl := range f.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.TYPE {
+				for _, spec := range gd.Specs {
+					ts := spec.(*ast.TypeSpec)
+					types.decls[r.pkgKey+"."+ts.Name.Name] = &typeDecl{spec: ts, file: r}
+				}
+			}
+		}
+	}
+
+	endpoints := make([]*endpoint, 0)
+	found := make(map[string]bool)
+	var routes [][2]string
+	for i, f := range files {
+		r := resolvers[i]
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if fn.Doc != nil {
+				if ep := parseAnnotations(fn, r, basePath); ep != nil {
+					endpoints = append(endpoints, ep)
+					found[ep.method+" "+ep.path] = true
+				}
+			}
+			if fn.Body != nil {
+				routes = append(routes, scanRoutes(fn.Body)...)
+			}
+		}
+	}
+
+	for _, rt := range routes {
+		if found[rt[0]+" "+rt[1]] {
+			continue
+		}
+		found[rt[0]+" "+rt[1]] = true
+		endpoints = append(endpoints, &endpoint{
+			name:   pathName(rt[0], rt[1]),
+			method: rt[0],
+			path:   rt[1],
+		})
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		if endpoints[i].path != endpoints[j].path {
+			return endpoints[i].path < endpoints[j].path
+		}
+		return endpoints[i].method < endpoints[j].method
+	})
+	uniqueNames(endpoints)
+	a := &api{endpoints: endpoints, imports: types.used}
+	for _, t := range types.local {
+		a.types = append(a.types, t)
+	}
+	sort.Slice(a.types, func(i, j int) bool { return a.types[i].name < a.types[j].name })
+	return a, nil
+}
+
+// findModule 向上查找go.mod，返回模块根目录与模块路径
+func findModule(dir string) (string, string) {
+	for d := dir; ; d = filepath.Dir(d) {
+		if f, err := os.Open(filepath.Join(d, "go.mod")); err == nil {
+			defer f.Close()
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				if strings.HasPrefix(line, "module ") {
+					return d, strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module ")), `"`)
+				}
+			}
+			return d, ""
+		}
+		if filepath.Dir(d) == d {
+			return "", ""
+		}
+	}
+}
+
+// fileImports 源文件中的包名→导入路径
+func fileImports(f *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range f.Imports {
+		p, _ := strconv.Unquote(spec.Path.Value)
+		name := path.Base(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = p
+	}
+	return imports
+}
+
+func annotationLines(cg *ast.CommentGroup) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(cg.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "@") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseAnnotations 解析函数上的swag注释，没有@Router时返回nil
+func parseAnnotations(fn *ast.FuncDecl, r *resolver, basePath string) *endpoint {
+	ep := &endpoint{typed: true}
+	var id string
+	var success string
+	for _, line := range annotationLines(fn.Doc) {
+		tag, value := line, ""
+		if i := strings.IndexAny(line, " \t"); i > 0 {
+			tag, value = line[:i], strings.TrimSpace(line[i:])
+		}
+		switch strings.ToLower(tag) {
+		case "@summary":
+			ep.summary = value
+		case "@description":
+			if ep.summary == "" {
+				ep.summary = value
+			}
+		case "@id":
+			id = value
+		case "@param":
+			m := paramRegexp.FindStringSubmatch(value)
+			if m == nil {
+				continue
+			}
+			if m[2] == "body" {
+				ep.body = r.goType(m[3])
+				continue
+			}
+			if m[3] == "file" {
+				fmt.Fprintf(os.Stderr, "mgin-gen: %s的文件参数%s已忽略，请使用client.Upload上传\n", fn.Name.Name, m[1])
+				continue
+			}
+			ep.params = append(ep.params, param{
+				name:     m[1],
+				in:       m[2],
+				typ:      r.goType(m[3]),
+				required: m[4] == "true",
+				comment:  m[5],
+			})
+		case "@success":
+			if success == "" {
+				success = value
+			}
+		case "@router":
+			if m := routerRegexp.FindStringSubmatch(value); m != nil {
+				ep.path = basePath + m[1]
+				ep.method = strings.ToUpper(m[2])
+			}
+		}
+	}
+	if ep.path == "" {
+		return nil
+	}
+	if m := successRegexp.FindStringSubmatch(success); m != nil {
+		r.result(ep, m[2], m[3])
+	}
+	switch {
+	case id != "":
+		ep.name = exportName(id)
+	case fn.Recv != nil && len(fn.Recv.List) > 0:
+		ep.name = exportName(strings.TrimSuffix(strings.TrimSuffix(receiverName(fn.Recv.List[0].Type), "Controller"), "controller")) + exportName(fn.Name.Name)
+	default:
+		ep.name = exportName(fn.Name.Name)
+	}
+	return ep
+}
+
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// result 解析@Success中的返回类型，models.Result{data=xxx}生成带类型的Data
+func (r *resolver) result(ep *endpoint, kind, typ string) {
+	base, data := typ, ""
+	if i := strings.Index(typ, "{"); i > 0 && strings.HasSuffix(typ, "}") {
+		base = typ[:i]
+		for _, kv := range strings.Split(typ[i+1:len(typ)-1], ",") {
+			pair := strings.SplitN(kv, "=", 2)
+			if len(pair) == 2 && strings.TrimSpace(pair[0]) == "data" {
+				data = strings.TrimSpace(pair[1])
+			}
+		}
+	}
+	if kind == "object" && r.isResult(base) {
+		if data != "" {
+			ep.data = r.goType(data)
+		}
+		return
+	}
+	ep.raw = r.goType(base)
+	if kind == "array" {
+		ep.raw = "[]" + ep.raw
+	}
+}
+
+func (r *resolver) isResult(t string) bool {
+	i := strings.LastIndex(t, ".")
+	if i < 0 || t[i+1:] != "Result" {
+		return false
+	}
+	p, ok := r.imports[t[:i]]
+	return t[:i] == modelsPath || ok && p == modelsPath || !ok && t[:i] == "models"
+}
+
+// goType 将swag类型转换为Go类型，并记录需要导入的包
+func (r *resolver) goType(t string) string {
+	if strings.HasPrefix(t, "[]") {
+		return "[]" + r.goType(t[2:])
+	}
+	switch t {
+	case "string", "int", "int32", "int64", "uint", "uint32", "uint64", "float32", "float64", "bool", "byte":
+		return t
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "", "object", "interface{}":
+		return "interface{}"
+	}
+	if i := strings.LastIndex(t, "."); i > 0 {
+		pkg, name := t[:i], t[i+1:]
+		p, ok := r.imports[pkg]
+		switch {
+		case ok:
+		case strings.Contains(pkg, "/"):
+			p, pkg = pkg, path.Base(pkg)
+		case pkg == "models":
+			p = modelsPath
+		default:
+			fmt.Fprintf(os.Stderr, "mgin-gen: 无法确定类型%s的导入路径，已替换为interface{}\n", t)
+			return "interface{}"
+		}
+		return r.named(p, pkg, name)
+	}
+	if _, ok := r.types.decls[r.pkgKey+"."+t]; ok {
+		return r.types.localName(r.pkgKey + "." + t)
+	}
+	fmt.Fprintf(os.Stderr, "mgin-gen: 未找到类型%s的定义，已替换为interface{}\n", t)
+	return "interface{}"
+}
+
+// named 服务端源码中定义的类型复制到生成代码中，其他包的类型记录导入
+func (r *resolver) named(p, pkg, name string) string {
+	if _, ok := r.types.decls[p+"."+name]; ok {
+		return r.types.localName(p + "." + name)
+	}
+	r.types.used[p] = pkg
+	return pkg + "." + name
+}
+
+// localName 获取服务端类型在生成代码中的名称，首次使用时复制其定义
+// 不同包的同名类型加上包名区分
+func (t *typeTable) localName(key string) string {
+	if lt, ok := t.local[key]; ok {
+		return lt.name
+	}
+	d := t.decls[key]
+	name := t.prefix + d.spec.Name.Name
+	if t.names[name] {
+		name = t.prefix + exportName(d.file.pkgName) + d.spec.Name.Name
+	}
+	for i := 2; t.names[name]; i++ {
+		name = fmt.Sprintf("%s%s%d", t.prefix, d.spec.Name.Name, i)
+	}
+	t.names[name] = true
+	lt := &localType{name: name, origin: d.file.pkgName + "." + d.spec.Name.Name, alias: d.spec.Assign.IsValid()}
+	t.local[key] = lt
+	lt.src = d.file.typeExpr(d.spec.Type)
+	return name
+}
+
+// typeExpr 将服务端类型定义中的类型表达式转换为生成代码中的类型
+func (r *resolver) typeExpr(expr ast.Expr) string {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		if basicTypes[x.Name] {
+			return x.Name
+		}
+		return r.goType(x.Name)
+	case *ast.SelectorExpr:
+		if pkg, ok := x.X.(*ast.Ident); ok {
+			if p, ok := r.imports[pkg.Name]; ok {
+				return r.named(p, pkg.Name, x.Sel.Name)
+			}
+		}
+	case *ast.StarExpr:
+		return "*" + r.typeExpr(x.X)
+	case *ast.ArrayType:
+		if lit, ok := x.Len.(*ast.BasicLit); ok {
+			return "[" + lit.Value + "]" + r.typeExpr(x.Elt)
+		}
+		return "[]" + r.typeExpr(x.Elt)
+	case *ast.MapType:
+		return "map[" + r.typeExpr(x.Key) + "]" + r.typeExpr(x.Value)
+	case *ast.InterfaceType:
+		return "interface{}"
+	case *ast.StructType:
+		return r.structType(x)
+	}
+	fmt.Fprintf(os.Stderr, "mgin-gen: 不支持的类型定义，已替换为interface{}\n")
+	return "interface{}"
+}
+
+// structType 复制结构体定义，未导出的字段不参与JSON编解码，不复制
+func (r *resolver) structType(st *ast.StructType) string {
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	for _, f := range st.Fields.List {
+		names := make([]string, 0, len(f.Names))
+		for _, n := range f.Names {
+			if n.IsExported() {
+				names = append(names, n.Name)
+			}
+		}
+		if len(f.Names) > 0 && len(names) == 0 {
+			continue
+		}
+		if len(names) > 0 {
+			b.WriteString(strings.Join(names, ", ") + " ")
+		}
+		b.WriteString(r.typeExpr(f.Type))
+		if f.Tag != nil {
+			b.WriteString(" " + f.Tag.Value)
+		}
+		if f.Comment != nil {
+			b.WriteString(" //" + strings.SplitN(strings.TrimSpace(f.Comment.Text()), "\n", 2)[0])
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// scanRoutes 查找函数体中engine.GET("/path", ...)形式的路由定义，支持Group前缀
+// 返回的路径参数已由:id转换为{id}
+func scanRoutes(body *ast.BlockStmt) [][2]string {
+	routes := make([][2]string, 0)
+	groups := make(map[string]string)
+	var prefix func(expr ast.Expr) string
+	prefix = func(expr ast.Expr) string {
+		switch x := expr.(type) {
+		case *ast.Ident:
+			return groups[x.Name]
+		case *ast.CallExpr:
+			if sel, ok := x.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Group" && len(x.Args) > 0 {
+				if p, ok := stringLit(x.Args[0]); ok {
+					return joinPath(prefix(sel.X), p)
+				}
+			}
+		}
+		return ""
+	}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+		case *ast.AssignStmt:
+			if len(x.Lhs) == 1 && len(x.Rhs) == 1 {
+				if id, ok := x.Lhs[0].(*ast.Ident); ok {
+					if p := prefix(x.Rhs[0]); p != "" {
+						groups[id.Name] = p
+					}
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := x.Fun.(*ast.SelectorExpr)
+			if !ok || len(x.Args) < 2 {
+				return true
+			}
+			method, uri := sel.Sel.Name, ""
+			if method == "Handle" {
+				method, _ = stringLit(x.Args[0])
+				uri, ok = stringLit(x.Args[1])
+			} else {
+				uri, ok = stringLit(x.Args[0])
+			}
+			if !ok || !routeMethods[method] || strings.Contains(uri, "*") {
+				return true
+			}
+			full := joinPath(prefix(sel.X), uri)
+			routes = append(routes, [2]string{method, ginPathRegexp.ReplaceAllString(full, "{$1}")})
+		}
+		return true
+	})
+	return routes
+}
+
+func joinPath(prefix, uri string) string {
+	return strings.TrimRight(prefix, "/") + "/" + strings.TrimLeft(uri, "/")
+}
+
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// pathName 由路径生成方法名，忽略api与版本号，如GET /api/v1/user/{id}为UserById
+// GET与POST以外的请求方法加在方法名前，如DeleteUserById
+func pathName(method, uri string) string {
+	var b strings.Builder
+	if method != "GET" && method != "POST" {
+		b.WriteString(exportName(strings.ToLower(method)))
+	}
+	for _, seg := range strings.Split(uri, "/") {
+		if seg == "" || seg == "api" || versionRegexp.MatchString(seg) {
+			continue
+		}
+		if strings.HasPrefix(seg, "{") {
+			b.WriteString("By" + exportName(strings.Trim(seg, "{}")))
+			continue
+		}
+		b.WriteString(exportName(seg))
+	}
+	if b.Len() == 0 {
+		return "Root"
+	}
+	return b.String()
+}
+
+// uniqueNames 同名方法追加请求方法或序号
+func uniqueNames(endpoints []*endpoint) {
+	names := make(map[string]bool)
+	for _, ep := range endpoints {
+		name := ep.name
+		if names[name] {
+			name = ep.name + exportName(strings.ToLower(ep.method))
+		}
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s%d", ep.name, i)
+		}
+		names[name] = true
+		ep.name = name
+	}
+}
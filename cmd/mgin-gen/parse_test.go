@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestParseDir(t *testing.T) {
+	a, err := parseDir("testdata/server", "Demo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for _, ep := range a.endpoints {
+		fmt.Fprintf(&buf, "%s %s %s typed=%v summary=%q body=%q data=%q raw=%q\n", ep.name, ep.method, ep.path, ep.typed, ep.summary, ep.body, ep.data, ep.raw)
+		for _, p := range ep.params {
+			fmt.Fprintf(&buf, "\tparam %s in=%s type=%s required=%v comment=%q\n", p.name, p.in, p.typ, p.required, p.comment)
+		}
+	}
+	paths := make([]string, 0, len(a.imports))
+	for p := range a.imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		fmt.Fprintf(&buf, "import %s %s\n", a.imports[p], p)
+	}
+	for _, lt := range a.types {
+		fmt.Fprintf(&buf, "type %s origin=%s alias=%v\n%s\n", lt.name, lt.origin, lt.alias, lt.src)
+	}
+	checkGolden(t, "parse.golden", buf.Bytes())
+}
+
+func TestPathName(t *testing.T) {
+	cases := []struct {
+		method, uri, want string
+	}{
+		{"GET", "/api/v1/user/{id}", "UserById"},
+		{"POST", "/user/add", "UserAdd"},
+		{"DELETE", "/api/v2/order/{id}", "DeleteOrderById"},
+		{"GET", "/", "Root"},
+	}
+	for _, c := range cases {
+		if got := pathName(c.method, c.uri); got != c.want {
+			t.Errorf("pathName(%s, %s)=%s，期望%s", c.method, c.uri, got, c.want)
+		}
+	}
+}
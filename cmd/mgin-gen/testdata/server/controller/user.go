@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/cmd/mgin-gen/testdata/server/dto"
+	"github.com/maczh/mgin/cmd/mgin-gen/testdata/server/model"
+	"github.com/maczh/mgin/models"
+)
+
+// Page 分页参数
+type Page struct {
+	Page int `json:"page"`
+	Size int `json:"size"`
+}
+
+// GetUser	godoc
+// @Summary		查询用户
+// @Param	id	path	int	true	"用户id"
+// @Param	fields	query	[]string	false	"返回字段"
+// @Param	X-Tenant	header	string	true	"租户"
+// @Success 200 {object} models.Result{data=model.User}
+// @Router	/user/{id} [get]
+func GetUser(c *gin.Context) {}
+
+// AddUser	godoc
+// @Summary		添加用户
+// @Param	user	body	model.User	true	"用户"
+// @Success 200 {object} models.Result{data=dto.User}
+// @Router	/user/add [post]
+func AddUser(c *gin.Context) {}
+
+// ListUsers	godoc
+// @Description		分页查询用户
+// @Param	name	formData	string	false	"姓名"
+// @Param	age	formData	integer	false	"年龄"
+// @Param	page	body	Page	true	"分页"
+// @Success 200 {array} model.User
+// @Router	/user/list [post]
+func ListUsers(c *gin.Context) {}
+
+// Ping	godoc
+// @ID		ping
+// @Success 200 {string} string
+// @Router	/ping [get]
+func Ping(c *gin.Context) {}
+
+// Status	godoc
+// @Success 200 {object} models.Result
+// @Router	/status [get]
+func Status(c *gin.Context) {}
+
+func DeleteOrder(c *gin.Context) {}
+
+func UpdateOrder(c *gin.Context) {
+	_ = models.Success(nil)
+}
@@ -0,0 +1,7 @@
+package dto
+
+// User 与model.User同名的返回结构
+type User struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/cmd/mgin-gen/testdata/server/controller"
+)
+
+// @title demo
+// @BasePath /api/v1
+func setupRouter() *gin.Engine {
+	engine := gin.Default()
+	engine.GET("/api/v1/user/:id", controller.GetUser)
+	engine.POST("/api/v1/user/add", controller.AddUser)
+	v1 := engine.Group("/api/v1")
+	order := v1.Group("/order")
+	order.DELETE("/:id", controller.DeleteOrder)
+	order.PUT("/:id", controller.UpdateOrder)
+	order.Handle("PATCH", "/:id/state", controller.UpdateOrder)
+	engine.GET("/static/*filepath", controller.GetUser)
+	return engine
+}
+
+func main() {
+	setupRouter().Run()
+}
@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// Base 公共字段
+type Base struct {
+	CreatedAt time.Time `json:"createdAt"`
+	version   int
+}
+
+// Role 角色
+type Role string
+
+// Tags 标签
+type Tags = []string
+
+// User 用户
+type User struct {
+	Base
+	Id      int64             `json:"id,string"` //用户id
+	Name    string            `json:"name"`
+	Roles   []Role            `json:"roles"`
+	Tags    Tags              `json:"tags"`
+	Profile *Profile          `json:"profile,omitempty"`
+	Extra   map[string]string `json:"extra"`
+	Codes   [2]int            `json:"codes"`
+	Any     interface{}       `json:"any"`
+	secret  string
+}
+
+// Profile 用户资料
+type Profile struct {
+	Address struct {
+		City string `json:"city"`
+	} `json:"address"`
+	Owner *User `json:"owner"`
+}
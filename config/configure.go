@@ -1,10 +1,17 @@
 package config
 
 import (
+	"fmt"
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/sadlil/gologger"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type config struct {
@@ -23,8 +30,12 @@ type app struct {
 	PortSSL int    `json:"portSSL" bson:"portSSL"`
 	Cert    string `json:"cert" bson:"cert"`
 	Key     string `json:"key" bson:"key"`
+	CA      string `json:"ca" bson:"ca"`
+	MTLS    bool   `json:"mtls" bson:"mtls"`
 	Debug   bool   `json:"debug" bson:"debug"`
 	IpAddr  string `json:"ipAddr" bson:"ipAddr"`
+	//是否将请求作用域写入协程缓存，兼容按协程id获取请求id与语言的旧代码
+	RoutineCache bool `json:"routineCache" bson:"routineCache"`
 }
 
 type appConfig struct {
@@ -43,9 +54,28 @@ type appConfig struct {
 }
 
 type appLogger struct {
-	Level string `json:"level" bson:"level"`
-	Out   string `json:"out" bson:"out"`
-	File  string `json:"file" bson:"file"`
+	Level  string `json:"level" bson:"level"`
+	Out    string `json:"out" bson:"out"`
+	File   string `json:"file" bson:"file"`
+	Format string `json:"format" bson:"format"`
+	//各包单独的日志级别，如client: debug
+	Levels map[string]string `json:"levels" bson:"levels"`
+	//日志文件切分与保留
+	MaxSize  int  `json:"max_size" bson:"max_size"`
+	MaxAge   int  `json:"max_age" bson:"max_age"`
+	MaxFiles int  `json:"max_files" bson:"max_files"`
+	Compress bool `json:"compress" bson:"compress"`
+	//异步输出
+	Async    bool   `json:"async" bson:"async"`
+	Buffer   int    `json:"buffer" bson:"buffer"`
+	Overflow string `json:"overflow" bson:"overflow"`
+	//输出到es与kafka
+	Sink struct {
+		Batch    int    `json:"batch" bson:"batch"`
+		Interval int    `json:"interval" bson:"interval"`
+		Index    string `json:"index" bson:"index"`
+		Topic    string `json:"topic" bson:"topic"`
+	} `json:"sink" bson:"sink"`
 }
 
 type appLog struct {
@@ -57,6 +87,22 @@ type appLog struct {
 		Use   bool   `json:"use" bson:"use"`
 		Topic string `json:"topic" bson:"topic"`
 	} `json:"kafka" bson:"kafka"`
+	//接口访问日志的路径过滤与采样
+	Include []string           `json:"include" bson:"include"`
+	Exclude []string           `json:"exclude" bson:"exclude"`
+	Sample  float64            `json:"sample" bson:"sample"`
+	Samples map[string]float64 `json:"samples" bson:"samples"`
+	//接口访问日志记录的请求与返回内容的最大字节数，0为不限制
+	MaxRequest  int `json:"max_request" bson:"max_request"`
+	MaxResponse int `json:"max_response" bson:"max_response"`
+	//出错与慢请求不受采样限制
+	OnError bool `json:"on_error" bson:"on_error"`
+	Slow    int  `json:"slow" bson:"slow"`
+	//接口访问日志批量写入
+	Buffer   int    `json:"buffer" bson:"buffer"`
+	Batch    int    `json:"batch" bson:"batch"`
+	Interval int    `json:"interval" bson:"interval"`
+	Overflow string `json:"overflow" bson:"overflow"`
 }
 
 type discovery struct {
@@ -64,7 +110,34 @@ type discovery struct {
 	CallType string `json:"callType" bson:"callType"`
 }
 
-var Config = &config{}
+// Config 启动时加载的配置，配置文件重新加载后不再修改，运行中读取配置请使用Current()
+var Config = &config{App: app{RoutineCache: true}}
+
+// current 当前生效的配置，重新加载时整体替换，避免与读取配置的请求协程并发读写
+var current atomic.Value
+
+// Current 获取当前生效的配置，配置文件重新加载后返回新的配置，返回的配置只读
+func Current() *config {
+	if c, ok := current.Load().(*config); ok {
+		return c
+	}
+	return Config
+}
+
+// snapshot 通过Config调用的方法读取当前生效的配置
+func (c *config) snapshot() *config {
+	if c == Config {
+		return Current()
+	}
+	return c
+}
+
+// 配置文件路径与配置重新加载后的回调
+var (
+	configFile     string
+	reloadLock     sync.Mutex
+	reloadHandlers []func()
+)
 
 var logger = gologger.GetLogger()
 
@@ -74,6 +147,7 @@ func (c *config) Init(cf string) {
 	if cf == "" {
 		cf = config_file
 	}
+	configFile = cf
 	logger.Debug("读取配置文件:" + cf)
 	c.Cnf = koanf.New(".")
 	f := file.Provider(cf)
@@ -81,14 +155,62 @@ func (c *config) Init(cf string) {
 	if err != nil {
 		logger.Error("读取配置文件错误:" + err.Error())
 	}
+	c.parse()
+	current.Store(c)
+	//配置文件修改后自动重新加载
+	if c.Cnf.Bool("go.config.watch") {
+		err = f.Watch(func(event interface{}, err error) {
+			if err != nil {
+				logger.Error("配置文件监控错误:" + err.Error())
+				return
+			}
+			c.Reload()
+		})
+		if err != nil {
+			logger.Error("配置文件监控失败:" + err.Error())
+		}
+	}
+}
+
+// Reload 重新读取配置文件生成新的配置后整体替换，并依次调用通过OnReload注册的回调
+func (c *config) Reload() error {
+	cnf := koanf.New(".")
+	if err := cnf.Load(file.Provider(configFile), yaml.Parser()); err != nil {
+		logger.Error("重新读取配置文件错误:" + err.Error())
+		return err
+	}
+	logger.Info("重新加载配置文件:" + configFile)
+	next := &config{Cnf: cnf}
+	next.parse()
+	reloadLock.Lock()
+	current.Store(next)
+	handlers := append([]func(){}, reloadHandlers...)
+	reloadLock.Unlock()
+	for _, h := range handlers {
+		h()
+	}
+	return nil
+}
+
+// OnReload 注册配置重新加载后的回调
+func (c *config) OnReload(f func()) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	reloadHandlers = append(reloadHandlers, f)
+}
+
+func (c *config) parse() {
 	c.App.Name = c.Cnf.String("go.application.name")
 	c.App.Project = c.Cnf.String("go.application.project")
 	c.App.Port = c.Cnf.Int("go.application.port")
 	c.App.PortSSL = c.Cnf.Int("go.application.port_ssl")
 	c.App.Cert = c.Cnf.String("go.application.cert")
 	c.App.Key = c.Cnf.String("go.application.key")
+	c.App.CA = c.Cnf.String("go.application.ca")
+	c.App.MTLS = c.Cnf.Bool("go.application.mtls")
 	c.App.Debug = c.Cnf.Bool("go.application.debug")
 	c.App.IpAddr = c.Cnf.String("go.application.ip")
+	c.App.RoutineCache = !c.Cnf.Exists("go.application.routine_cache") || c.Cnf.Bool("go.application.routine_cache")
 	c.Config.Server = c.Cnf.String("go.config.server")
 	c.Config.Type = c.Cnf.String("go.config.server_type")
 	c.Config.Env = c.Cnf.String("go.config.env")
@@ -108,14 +230,81 @@ func (c *config) Init(cf string) {
 	if c.Log.Kafka.Topic == "" {
 		c.Log.Kafka.Topic = c.App.Name
 	}
+	c.Log.Include = c.stringList("go.log.include")
+	c.Log.Exclude = c.stringList("go.log.exclude")
+	c.Log.Sample = 1
+	if c.Cnf.Exists("go.log.sample") {
+		c.Log.Sample = c.Cnf.Float64("go.log.sample")
+	}
+	c.Log.Samples = c.floatMap("go.log.samples")
+	c.Log.MaxRequest = c.Cnf.Int("go.log.max_request")
+	c.Log.MaxResponse = c.Cnf.Int("go.log.max_response")
+	c.Log.OnError = !c.Cnf.Exists("go.log.on_error") || c.Cnf.Bool("go.log.on_error")
+	c.Log.Slow = c.Cnf.Int("go.log.slow")
+	c.Log.Buffer = c.Cnf.Int("go.log.buffer")
+	c.Log.Batch = c.Cnf.Int("go.log.batch")
+	c.Log.Interval = c.Cnf.Int("go.log.interval")
+	c.Log.Overflow = c.Cnf.String("go.log.overflow")
 	c.Logger.Level = c.Cnf.String("go.logger.level")
+	c.Logger.Levels = c.Cnf.StringMap("go.logger.levels")
 	c.Logger.Out = c.Cnf.String("go.logger.out")
 	c.Logger.File = c.Cnf.String("go.logger.file")
+	c.Logger.Format = c.Cnf.String("go.logger.format")
+	c.Logger.MaxSize = c.Cnf.Int("go.logger.max_size")
+	c.Logger.MaxAge = c.Cnf.Int("go.logger.max_age")
+	c.Logger.MaxFiles = c.Cnf.Int("go.logger.max_files")
+	c.Logger.Compress = c.Cnf.Bool("go.logger.compress")
+	c.Logger.Async = c.Cnf.Bool("go.logger.async")
+	c.Logger.Buffer = c.Cnf.Int("go.logger.buffer")
+	c.Logger.Overflow = c.Cnf.String("go.logger.overflow")
+	c.Logger.Sink.Batch = c.Cnf.Int("go.logger.sink.batch")
+	c.Logger.Sink.Interval = c.Cnf.Int("go.logger.sink.interval")
+	c.Logger.Sink.Index = c.Cnf.String("go.logger.sink.index")
+	c.Logger.Sink.Topic = c.Cnf.String("go.logger.sink.topic")
 	c.Discovery.Registry = c.Cnf.String("go.discovery.registry")
 	c.Discovery.CallType = c.Cnf.String("go.discovery.callType")
 }
 
+// stringList 支持yaml列表与逗号分隔的字符串
+func (c *config) stringList(name string) []string {
+	if l := c.Cnf.Strings(name); len(l) > 0 {
+		return l
+	}
+	list := make([]string, 0)
+	for _, s := range strings.Split(c.Cnf.String(name), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// floatMap 值为数字的map，键中不能包含.
+func (c *config) floatMap(name string) map[string]float64 {
+	m := make(map[string]float64)
+	values, ok := c.Cnf.Get(name).(map[string]interface{})
+	if !ok {
+		return m
+	}
+	for k, v := range values {
+		if f, err := strconv.ParseFloat(fmt.Sprint(v), 64); err == nil {
+			m[k] = f
+		}
+	}
+	return m
+}
+
+// GetConfigStrings 获取yaml列表或逗号分隔的字符串配置
+func (c *config) GetConfigStrings(name string) []string {
+	c = c.snapshot()
+	if c.Cnf == nil {
+		return nil
+	}
+	return c.stringList(name)
+}
+
 func (c *config) GetConfigString(name string) string {
+	c = c.snapshot()
 	if c.Cnf == nil {
 		return ""
 	}
@@ -127,6 +316,7 @@ func (c *config) GetConfigString(name string) string {
 }
 
 func (c *config) GetConfigInt(name string) int {
+	c = c.snapshot()
 	if c.Cnf == nil {
 		return 0
 	}
@@ -138,6 +328,7 @@ func (c *config) GetConfigInt(name string) int {
 }
 
 func (c *config) GetConfigBool(name string) bool {
+	c = c.snapshot()
 	if c.Cnf == nil {
 		return false
 	}
@@ -149,6 +340,7 @@ func (c *config) GetConfigBool(name string) bool {
 }
 
 func (c *config) Exists(name string) bool {
+	c = c.snapshot()
 	if c.Cnf == nil {
 		return false
 	}
@@ -156,6 +348,7 @@ func (c *config) Exists(name string) bool {
 }
 
 func (c *config) GetConfigUrl(prefix string) string {
+	c = c.snapshot()
 	configUrl := c.Config.Server
 	switch c.Config.Type {
 	case "nacos":
@@ -169,3 +362,12 @@ func (c *config) GetConfigUrl(prefix string) string {
 	}
 	return configUrl
 }
+
+// AbsPath 将相对路径转换为相对于程序所在目录的绝对路径
+func (c *config) AbsPath(file string) string {
+	if file == "" || filepath.IsAbs(file) {
+		return file
+	}
+	path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
+	return filepath.Join(path, file)
+}
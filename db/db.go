@@ -1,11 +1,14 @@
 package db
 
 import (
+	"github.com/maczh/mgin/config"
 	"github.com/maczh/mgin/db/es"
 	"github.com/maczh/mgin/db/kafka"
 	"github.com/maczh/mgin/db/mongo"
 	"github.com/maczh/mgin/db/mysql"
 	"github.com/maczh/mgin/db/redis"
+	"github.com/maczh/mgin/logs"
+	"strings"
 )
 
 var Mysql = &mysql.MysqlClient{}
@@ -13,3 +16,21 @@ var Mongo = &mongo.Mongodb{}
 var Redis = &redis.RedisClient{}
 var ElasticSearch = &es.ElasticSearch{}
 var Kafka = &kafka.Kafka{}
+
+// 注册logs输出到es与kafka，在go.logger.out中配置es或kafka后生效
+func init() {
+	logs.RegisterSink(logs.ELASTICSEARCH, logs.SinkFunc(func(docs []string) error {
+		return ElasticSearch.BulkIndex(logSinkName(config.Current().Logger.Sink.Index), docs)
+	}))
+	logs.RegisterSink(logs.KAFKA, logs.SinkFunc(func(docs []string) error {
+		return Kafka.SendMsgsSync(logSinkName(config.Current().Logger.Sink.Topic), docs)
+	}))
+}
+
+// logSinkName 日志的索引或主题名称，未配置时为应用名称_log
+func logSinkName(name string) string {
+	if name != "" {
+		return name
+	}
+	return strings.ToLower(config.Current().App.Name) + "_log"
+}
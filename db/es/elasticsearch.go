@@ -6,10 +6,9 @@ import (
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/levigross/grequests"
+	"github.com/maczh/mgin/logs"
 	"github.com/olivere/elastic"
 	"github.com/sadlil/gologger"
-	"log"
-	"os"
 )
 
 type ElasticSearch struct {
@@ -20,6 +19,12 @@ type ElasticSearch struct {
 
 var logger = gologger.GetLogger()
 
+// es客户端的日志输出到logs，不发送到外部日志存储，避免日志写入es时产生的日志再次写入
+var (
+	infoLog  = &logs.StdLogger{Level: "info", NoSink: true}
+	errorLog = &logs.StdLogger{Level: "error", NoSink: true}
+)
+
 func (e *ElasticSearch) Init(elasticConfigUrl string) {
 	if elasticConfigUrl != "" {
 		e.confUrl = elasticConfigUrl
@@ -45,9 +50,9 @@ func (e *ElasticSearch) Init(elasticConfigUrl string) {
 		password := e.conf.String("go.elasticsearch.password")
 		if user != "" && password != "" {
 			//logger.Debug("user:"+user+"   password:"+password)
-			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetBasicAuth(user, password), elastic.SetInfoLog(log.New(os.Stdout, "Elasticsearch", log.LstdFlags)), elastic.SetSniff(false))
+			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetBasicAuth(user, password), elastic.SetInfoLog(infoLog), elastic.SetErrorLog(errorLog), elastic.SetSniff(false), elastic.SetHttpClient(traceHttpClient))
 		} else {
-			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetInfoLog(log.New(os.Stdout, "Elasticsearch", log.LstdFlags)), elastic.SetSniff(false))
+			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetInfoLog(infoLog), elastic.SetErrorLog(errorLog), elastic.SetSniff(false), elastic.SetHttpClient(traceHttpClient))
 		}
 		if err != nil {
 			logger.Error("Elasticsearch连接错误:" + err.Error())
@@ -57,9 +62,9 @@ func (e *ElasticSearch) Init(elasticConfigUrl string) {
 		password := e.conf.String("go.elasticsearch.password")
 		if user != "" && password != "" {
 			//logger.Debug("user:"+user+"   password:"+password)
-			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetBasicAuth(user, password), elastic.SetInfoLog(log.New(os.Stdout, "Elasticsearch", log.LstdFlags)), elastic.SetSniff(false))
+			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetBasicAuth(user, password), elastic.SetInfoLog(infoLog), elastic.SetErrorLog(errorLog), elastic.SetSniff(false), elastic.SetHttpClient(traceHttpClient))
 		} else {
-			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetInfoLog(log.New(os.Stdout, "Elasticsearch", log.LstdFlags)), elastic.SetSniff(false))
+			e.Elastic, err = elastic.NewClient(elastic.SetURL(e.conf.String("go.elasticsearch.uri")), elastic.SetInfoLog(infoLog), elastic.SetErrorLog(errorLog), elastic.SetSniff(false), elastic.SetHttpClient(traceHttpClient))
 		}
 		if err != nil {
 			logger.Error("Elasticsearch连接错误:" + err.Error())
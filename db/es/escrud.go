@@ -2,6 +2,7 @@ package es
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gofrs/uuid"
@@ -21,21 +22,12 @@ func (e *ElasticSearch) AddDocument(database, table string, doc map[string]inter
 		doc["id"] = uuid.String()
 	} else {
 		switch doc["id"].(type) {
-		case float64, int64:
+		case float64, int64, json.Number:
 			doc["id"] = fmt.Sprintf("%v", doc["id"])
 		}
 	}
-	if exists, _ := elastic.NewIndicesExistsService(e.Elastic).Index([]string{indexName}).Do(context.TODO()); !exists {
-		//新建Index
-		settings := buildIKPinyinSettings()
-		mappings := buildMappings(doc, searchFields)
-		settings["mappings"] = mappings
-		logs.Debug("settings={}", settings)
-		_, err := e.Elastic.CreateIndex(indexName).BodyJson(settings).Do(context.TODO())
-		if err != nil {
-			logs.Error("创建Index错误:{}", err.Error())
-			return "", err
-		}
+	if err := e.CreateIndexIfNotExists(indexName, doc, searchFields); err != nil {
+		return "", err
 	}
 	resp, err := e.Elastic.Index().Index(indexName).Type("_doc").Id(doc["id"].(string)).BodyJson(doc).Do(context.TODO())
 	logs.Debug("插入文档结果:{}", resp)
@@ -61,22 +53,13 @@ func (e *ElasticSearch) AddDocuments(database, table string, docs []map[string]i
 			doc["id"] = uid.String()
 		} else {
 			switch doc["id"].(type) {
-			case float64, int64:
+			case float64, int64, json.Number:
 				doc["id"] = fmt.Sprintf("%v", doc["id"])
 			}
 		}
 	}
-	if exists, _ := elastic.NewIndicesExistsService(e.Elastic).Index([]string{indexName}).Do(context.TODO()); !exists {
-		//新建Index
-		settings := buildIKPinyinSettings()
-		mappings := buildMappings(docs[0], searchFields)
-		settings["mappings"] = mappings
-		logs.Debug("settings={}", settings)
-		_, err := e.Elastic.CreateIndex(indexName).BodyJson(settings).Do(context.TODO())
-		if err != nil {
-			logs.Error("创建Index错误:{}", err.Error())
-			return nil, err
-		}
+	if err := e.CreateIndexIfNotExists(indexName, docs[0], searchFields); err != nil {
+		return nil, err
 	}
 	bulk := e.Elastic.Bulk()
 	ids := make([]string, len(docs))
@@ -96,6 +79,43 @@ func (e *ElasticSearch) AddDocuments(database, table string, docs []map[string]i
 	}
 }
 
+// CreateIndexIfNotExists 索引不存在时按IK与拼音分词创建，字段映射由样例文档doc生成
+func (e *ElasticSearch) CreateIndexIfNotExists(indexName string, doc map[string]interface{}, searchFields []string) error {
+	if exists, _ := elastic.NewIndicesExistsService(e.Elastic).Index([]string{indexName}).Do(context.TODO()); !exists {
+		//新建Index
+		settings := buildIKPinyinSettings()
+		mappings := buildMappings(doc, searchFields)
+		settings["mappings"] = mappings
+		logs.Debug("settings={}", settings)
+		_, err := e.Elastic.CreateIndex(indexName).BodyJson(settings).Do(context.TODO())
+		if err != nil {
+			logs.Error("创建Index错误:{}", err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// BulkIndex 批量写入json格式的文档，文档id由ElasticSearch生成，用于日志等只追加的数据
+// 不通过logs输出日志，避免日志写入ElasticSearch时循环调用
+func (e *ElasticSearch) BulkIndex(indexName string, docs []string) error {
+	if e.Elastic == nil {
+		return errors.New("ElasticSearch未连接")
+	}
+	bulk := e.Elastic.Bulk()
+	for _, doc := range docs {
+		bulk.Add(elastic.NewBulkIndexRequest().Index(indexName).Type("_doc").Doc(json.RawMessage(doc)))
+	}
+	resp, err := bulk.Do(context.Background())
+	if err != nil {
+		return err
+	}
+	if resp.Errors {
+		return errors.New("批量入库存在错误")
+	}
+	return nil
+}
+
 func (e *ElasticSearch) DeleteDocument(database, table string, id string) (bool, error) {
 	indexName := fmt.Sprintf("%s_%s", database, table)
 	if table == "" {
@@ -228,7 +248,7 @@ func buildMappings(doc map[string]interface{}, serachFields []string) map[string
 				//properties[k+"Jpy"] = jpyMapping
 			}
 			fieldMapping["fields"] = subFieldMapping
-		case float64:
+		case float64, json.Number:
 			n := fmt.Sprintf("%v", v)
 			if strings.Contains(n, ".") {
 				fieldMapping["type"] = "double"
@@ -277,7 +297,7 @@ func buildMappings(doc map[string]interface{}, serachFields []string) map[string
 					//properties[k+"Jpy"] = jpyMapping
 				}
 				fieldMapping["fields"] = subFieldMapping
-			case float64:
+			case float64, json.Number:
 				n := fmt.Sprintf("%v", v)
 				if strings.Contains(n, ".") {
 					fieldMapping["type"] = "double"
@@ -0,0 +1,28 @@
+package es
+
+import (
+	"fmt"
+	"github.com/maczh/mgin/tracing"
+	"net/http"
+)
+
+// traceHttpClient 为每个ElasticSearch请求创建span，父span取自Do(ctx)传入的ctx
+var traceHttpClient = &http.Client{Transport: &traceTransport{base: http.DefaultTransport}}
+
+type traceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !tracing.Enabled() {
+		return t.base.RoundTrip(req)
+	}
+	ctx, end := tracing.StartDB(req.Context(), "elasticsearch", req.Method, req.URL.Path)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+		end(fmt.Errorf("elasticsearch返回状态码%d", resp.StatusCode))
+	} else {
+		end(err)
+	}
+	return resp, err
+}
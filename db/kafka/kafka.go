@@ -9,8 +9,12 @@ import (
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/levigross/grequests"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/middleware/trace"
+	"github.com/maczh/mgin/tracing"
 	"github.com/sadlil/gologger"
 	"strings"
+	"sync"
 )
 
 type Kafka struct {
@@ -20,10 +24,18 @@ type Kafka struct {
 	topics  []string
 	servers []string
 	config  *sarama.Config
+	//同步发送使用单独的连接，需等待发送结果
+	syncProducer sarama.SyncProducer
+	syncLock     sync.Mutex
 }
 
 var logger = gologger.GetLogger()
 
+func init() {
+	//sarama的日志按debug级别输出到logs，不发送到外部日志存储，避免日志发送到kafka时产生的日志再次发送
+	sarama.Logger = &logs.StdLogger{Level: "debug", NoSink: true}
+}
+
 func (k *Kafka) getConfig() *sarama.Config {
 	ack := k.conf.String("go.data.kafka.ack")
 	autoCommit := k.conf.Bool("go.data.kafka.auto_commit")
@@ -94,6 +106,12 @@ func (k *Kafka) Init(kafkaConfigUrl string) {
 }
 
 func (k *Kafka) Close() {
+	k.syncLock.Lock()
+	if k.syncProducer != nil {
+		k.syncProducer.Close()
+		k.syncProducer = nil
+	}
+	k.syncLock.Unlock()
 	err := k.client.Close()
 	if err != nil {
 		logger.Error("Kafka关闭连接失败: " + err.Error())
@@ -147,6 +165,11 @@ func (k *Kafka) CreateTopic(topic string) error {
 }
 
 func (k *Kafka) Send(topic, data string) error {
+	return k.SendContext(context.Background(), topic, data)
+}
+
+// SendContext 发送消息，请求id与链路信息以消息头随消息传递，ctx没有请求作用域时从当前协程获取
+func (k *Kafka) SendContext(ctx context.Context, topic, data string) error {
 	if !stringArrayContains(k.topics, topic) {
 		err := k.CreateTopic(topic)
 		if err != nil {
@@ -160,9 +183,13 @@ func (k *Kafka) Send(topic, data string) error {
 		logger.Error("Kafka连接失败:" + err.Error())
 		return err
 	}
+	ctx, headers := sendContext(ctx)
+	ctx, span := startProducerSpan(ctx, topic)
+	defer span.End()
 	msg := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(data),
+		Topic:   topic,
+		Value:   sarama.StringEncoder(data),
+		Headers: k.recordHeaders(ctx, headers),
 	}
 	producer.Input() <- msg
 	logger.Debug(fmt.Sprintf("Kafka发送消息到%s成功!内容:%s", topic, data))
@@ -170,6 +197,11 @@ func (k *Kafka) Send(topic, data string) error {
 }
 
 func (k *Kafka) SendMsgs(topic string, data []string) error {
+	return k.SendMsgsContext(context.Background(), topic, data)
+}
+
+// SendMsgsContext 批量发送消息，同SendContext
+func (k *Kafka) SendMsgsContext(ctx context.Context, topic string, data []string) error {
 	if !stringArrayContains(k.topics, topic) {
 		err := k.CreateTopic(topic)
 		if err != nil {
@@ -186,17 +218,69 @@ func (k *Kafka) SendMsgs(topic string, data []string) error {
 	if data == nil || len(data) == 0 {
 		return errors.New("No data to send")
 	}
+	ctx, headers := sendContext(ctx)
+	ctx, span := startProducerSpan(ctx, topic)
+	defer span.End()
+	recordHeaders := k.recordHeaders(ctx, headers)
 	for _, d := range data {
 		msg := &sarama.ProducerMessage{
-			Topic: topic,
-			Value: sarama.StringEncoder(d),
+			Topic:   topic,
+			Value:   sarama.StringEncoder(d),
+			Headers: recordHeaders,
 		}
 		producer.Input() <- msg
 	}
 	return nil
 }
 
+// SendMsgsSync 批量发送消息并等待全部写入成功，任一消息发送失败时返回错误，用于日志等需确认发送结果的场景
+func (k *Kafka) SendMsgsSync(topic string, data []string) error {
+	if len(data) == 0 {
+		return errors.New("No data to send")
+	}
+	producer, err := k.getSyncProducer()
+	if err != nil {
+		return err
+	}
+	msgs := make([]*sarama.ProducerMessage, 0, len(data))
+	for _, d := range data {
+		msgs = append(msgs, &sarama.ProducerMessage{Topic: topic, Value: sarama.StringEncoder(d)})
+	}
+	return producer.SendMessages(msgs)
+}
+
+// getSyncProducer 获取同步发送的producer，首次使用时创建
+func (k *Kafka) getSyncProducer() (sarama.SyncProducer, error) {
+	k.syncLock.Lock()
+	defer k.syncLock.Unlock()
+	if k.syncProducer != nil {
+		return k.syncProducer, nil
+	}
+	if k.conf == nil {
+		return nil, errors.New("Kafka未初始化")
+	}
+	config := k.getConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	producer, err := sarama.NewSyncProducer(k.servers, config)
+	if err != nil {
+		return nil, err
+	}
+	k.syncProducer = producer
+	return producer, nil
+}
+
+// MessageListener 监听消息，处理前将消息头中的请求id恢复到当前协程，日志可关联到发送方的请求
 func (k *Kafka) MessageListener(groupId, topic string, listener func(msg string) error) error {
+	return k.listen(groupId, topic, MsgHandler{Handle: listener, groupId: groupId})
+}
+
+// MessageListenerContext 监听消息，listener的ctx携带由消息头恢复的请求作用域与链路信息
+func (k *Kafka) MessageListenerContext(groupId, topic string, listener func(ctx context.Context, msg string) error) error {
+	return k.listen(groupId, topic, MsgHandler{handleContext: listener, groupId: groupId})
+}
+
+func (k *Kafka) listen(groupId, topic string, handler MsgHandler) error {
 	if !stringArrayContains(k.topics, topic) {
 		err := k.CreateTopic(topic)
 		if err != nil {
@@ -205,9 +289,6 @@ func (k *Kafka) MessageListener(groupId, topic string, listener func(msg string)
 		}
 		k.topics = append(k.topics, topic)
 	}
-	handler := MsgHandler{
-		Handle: listener,
-	}
 	consumerGroup, err := k.GetConsumerGroup(groupId)
 	if err != nil {
 		logger.Error("Kafka获取consumerGroup失败:" + err.Error())
@@ -223,7 +304,9 @@ func (k *Kafka) MessageListener(groupId, topic string, listener func(msg string)
 }
 
 type MsgHandler struct {
-	Handle func(msg string) error
+	Handle        func(msg string) error
+	handleContext func(ctx context.Context, msg string) error
+	groupId       string
 }
 
 func (MsgHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
@@ -231,15 +314,26 @@ func (MsgHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
 func (h MsgHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	for msg := range claim.Messages() {
 		//logger.Debug(fmt.Sprintf("Message topic:%q partition:%d offset:%d, msg: %s\n", msg.Topic, msg.Partition, msg.Offset, string(msg.Value)))
-		err := h.Handle(string(msg.Value))
+		ctx, span := consumeContext(h.groupId, msg)
+		err := h.handle(ctx, string(msg.Value))
 		if err != nil {
 			logger.Error("Kafka消息消费处理错误: " + err.Error())
 		}
+		tracing.End(span, err)
 		sess.MarkMessage(msg, "")
 	}
 	return nil
 }
 
+// handle 处理消息，不带ctx的旧处理函数处理期间将请求头保存到当前协程，按协程id获取请求id
+func (h MsgHandler) handle(ctx context.Context, msg string) error {
+	if h.handleContext != nil {
+		return h.handleContext(ctx, msg)
+	}
+	defer trace.BindRoutine(ctx)()
+	return h.Handle(msg)
+}
+
 func stringArrayContains(src []string, dst string) bool {
 	if src == nil || len(src) == 0 {
 		return false
@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"context"
+	"github.com/Shopify/sarama"
+	"github.com/maczh/mgin/middleware/trace"
+	"github.com/maczh/mgin/scope"
+	"github.com/maczh/mgin/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+// startProducerSpan 创建发送消息的span
+func startProducerSpan(ctx context.Context, topic string) (context.Context, oteltrace.Span) {
+	return tracing.Start(ctx, topic+" send", oteltrace.SpanKindProducer,
+		semconv.MessagingSystemKey.String("kafka"),
+		semconv.MessagingDestinationKey.String(topic),
+		semconv.MessagingDestinationKindTopic,
+	)
+}
+
+// startConsumerSpan 创建处理消息的span
+func startConsumerSpan(ctx context.Context, groupId string, msg *sarama.ConsumerMessage) (context.Context, oteltrace.Span) {
+	return tracing.Start(ctx, msg.Topic+" process", oteltrace.SpanKindConsumer,
+		semconv.MessagingSystemKey.String("kafka"),
+		semconv.MessagingDestinationKey.String(msg.Topic),
+		semconv.MessagingDestinationKindTopic,
+		semconv.MessagingOperationProcess,
+		semconv.MessagingKafkaConsumerGroupKey.String(groupId),
+		semconv.MessagingKafkaPartitionKey.Int(int(msg.Partition)),
+		attribute.Int64("messaging.kafka.offset", msg.Offset),
+	)
+}
+
+// 随消息以消息头传递的请求头，消费时恢复到请求作用域
+var propagateHeaders = []string{"X-Request-Id", "X-Lang", "Traceparent", "Tracestate", "Baggage"}
+
+// sendContext 获取发送消息时的请求头与父span，ctx没有请求作用域时从当前协程的缓存中获取
+func sendContext(ctx context.Context) (context.Context, map[string]string) {
+	var headers map[string]string
+	if s := scope.FromContext(ctx); s != nil {
+		headers = s.CopyHeaders()
+	} else {
+		headers = trace.GetHeaders()
+	}
+	if !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		ctx = tracing.Extract(ctx, headers)
+	}
+	return ctx, headers
+}
+
+// recordHeaders 以发送消息的span生成消息头，Kafka版本低于0.11不支持消息头时返回nil
+func (k *Kafka) recordHeaders(ctx context.Context, headers map[string]string) []sarama.RecordHeader {
+	if k.config == nil || !k.config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil
+	}
+	h := make(map[string]string)
+	for key, v := range headers {
+		h[key] = v
+	}
+	tracing.Inject(ctx, h)
+	var recordHeaders []sarama.RecordHeader
+	for _, name := range propagateHeaders {
+		if h[name] != "" {
+			recordHeaders = append(recordHeaders, sarama.RecordHeader{Key: []byte(name), Value: []byte(h[name])})
+		}
+	}
+	return recordHeaders
+}
+
+// consumeContext 以消息头恢复请求作用域并创建处理消息的span
+func consumeContext(groupId string, msg *sarama.ConsumerMessage) (context.Context, oteltrace.Span) {
+	headers := make(map[string]string)
+	for _, h := range msg.Headers {
+		if h != nil {
+			headers[http.CanonicalHeaderKey(string(h.Key))] = string(h.Value)
+		}
+	}
+	ctx := tracing.Extract(context.Background(), headers)
+	ctx, span := startConsumerSpan(ctx, groupId, msg)
+	return trace.ContextWithHeaders(ctx, headers), span
+}
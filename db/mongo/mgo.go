@@ -7,10 +7,9 @@ import (
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/levigross/grequests"
+	"github.com/maczh/mgin/logs"
 	"github.com/sadlil/gologger"
 	"gopkg.in/mgo.v2"
-	"log"
-	"os"
 	"strings"
 )
 
@@ -57,9 +56,7 @@ func (m *Mongodb) Init(mongodbConfigUrl string) {
 		}
 		if m.conf.Bool("go.data.mongodb.debug") {
 			mgo.SetDebug(true)
-			var mgoLogger *log.Logger
-			mgoLogger = log.New(os.Stderr, "", log.LstdFlags)
-			mgo.SetLogger(mgoLogger)
+			mgo.SetLogger(logs.NewStdLogger("info"))
 		}
 		m.multi = m.conf.Bool("go.data.mongodb.multidb")
 		if m.multi {
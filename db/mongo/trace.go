@@ -0,0 +1,22 @@
+package mongo
+
+import (
+	"context"
+	"github.com/maczh/mgin/tracing"
+	"gopkg.in/mgo.v2"
+)
+
+// StartSpan 创建mongodb操作的span，mgo没有命令钩子，需在操作前后调用
+//
+//	ctx, end := db.Mongo.StartSpan(ctx, "user", "find")
+//	err := conn.C("user").Find(query).All(&users)
+//	end(err)
+func (m *Mongodb) StartSpan(ctx context.Context, collection, operation string) (context.Context, func(err error)) {
+	ctx, end := tracing.StartDB(ctx, "mongodb", operation, collection)
+	return ctx, func(err error) {
+		if err == mgo.ErrNotFound {
+			err = nil
+		}
+		end(err)
+	}
+}
@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/maczh/mgin/logs"
+	gormlogger "gorm.io/gorm/logger"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// 默认慢查询阈值，可通过go.data.mysql_slow配置，单位毫秒
+const defaultSlowThreshold = 200 * time.Millisecond
+
+// gormLogger 将gorm的日志输出到logs，请求id取自db.WithContext(ctx)传入的ctx
+// SQL在Info模式(mysql_debug)下按info输出，慢查询按warn输出，执行错误按error输出，记录不存在不作为错误
+type gormLogger struct {
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+}
+
+func newGormLogger(slowThreshold time.Duration) gormlogger.Interface {
+	if slowThreshold <= 0 {
+		slowThreshold = defaultSlowThreshold
+	}
+	return &gormLogger{level: gormlogger.Warn, slowThreshold: slowThreshold}
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	nl := *l
+	nl.level = level
+	return &nl
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.log(ctx, "info", fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.log(ctx, "warn", fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.log(ctx, "error", fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gormlogger.ErrRecordNotFound):
+		sql, rows := fc()
+		l.log(ctx, "error", sql, logs.Field{Key: "error", Value: err}, logs.Field{Key: "rows", Value: rows}, logs.Field{Key: "elapsed", Value: elapsed.Milliseconds()})
+	case elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		l.log(ctx, "warn", sql, logs.Field{Key: "slow", Value: l.slowThreshold.String()}, logs.Field{Key: "rows", Value: rows}, logs.Field{Key: "elapsed", Value: elapsed.Milliseconds()})
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		l.log(ctx, "info", sql, logs.Field{Key: "rows", Value: rows}, logs.Field{Key: "elapsed", Value: elapsed.Milliseconds()})
+	}
+}
+
+// log 调用位置为gorm与本包之外的第一个调用方，即业务代码中执行数据库操作的位置
+func (l *gormLogger) log(ctx context.Context, level, msg string, fields ...logs.Field) {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	depth := 1
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "gorm.io/") && !strings.HasPrefix(frame.Function, "github.com/maczh/mgin/db/mysql.") {
+			break
+		}
+		if !more {
+			depth = 1
+			break
+		}
+		depth++
+	}
+	logs.LogDepth(ctx, depth, level, msg, fields...)
+}
@@ -48,6 +48,7 @@ func (m *MysqlClient) Init(mysqlConfigUrl string) {
 			}
 		}
 		m.multi = false
+		gormLog := newGormLogger(time.Duration(m.conf.Int("go.data.mysql_slow")) * time.Millisecond)
 		if m.conf.Exists("go.data.mysql.multi") && m.conf.Bool("go.data.mysql.multi") {
 			m.multi = true
 			m.mysqls = make(map[string]*gorm.DB)
@@ -55,17 +56,21 @@ func (m *MysqlClient) Init(mysqlConfigUrl string) {
 			dbNames := strings.Split(m.conf.String("go.data.mysql.dbNames"), ",")
 			for _, dbName := range dbNames {
 				if dbName != "" && m.conf.String("go.data.mysql."+dbName) != "" {
-					conn, err := gorm.Open(mysql.Open(m.conf.String("go.data.mysql."+dbName)), &gorm.Config{})
+					conn, err := gorm.Open(mysql.Open(m.conf.String("go.data.mysql."+dbName)), &gorm.Config{Logger: gormLog})
 					if err != nil {
 						logger.Error(dbName + " mysql connection error:" + err.Error())
 						continue
 					}
+					conn.Use(tracePlugin{})
 					m.mysqls[dbName] = conn
 					m.conns = append(m.conns, dbName)
 				}
 			}
 		} else {
-			m.mysql, _ = gorm.Open(mysql.Open(m.conf.String("go.data.mysql")), &gorm.Config{})
+			m.mysql, _ = gorm.Open(mysql.Open(m.conf.String("go.data.mysql")), &gorm.Config{Logger: gormLog})
+			if m.mysql != nil {
+				m.mysql.Use(tracePlugin{})
+			}
 		}
 		if m.conf.Bool("go.data.mysql_debug") {
 			if m.multi {
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"errors"
+	"github.com/maczh/mgin/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "mgin:span"
+
+// tracePlugin 为gorm的每次数据库操作创建span，父span取自db.WithContext(ctx)传入的ctx
+type tracePlugin struct{}
+
+func (tracePlugin) Name() string {
+	return "mgin:tracing"
+}
+
+func (p tracePlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	errs := []error{
+		cb.Create().Before("gorm:create").Register("mgin:trace_before_insert", p.before("insert")),
+		cb.Create().After("gorm:create").Register("mgin:trace_after_insert", p.after),
+		cb.Query().Before("gorm:query").Register("mgin:trace_before_select", p.before("select")),
+		cb.Query().After("gorm:query").Register("mgin:trace_after_select", p.after),
+		cb.Update().Before("gorm:update").Register("mgin:trace_before_update", p.before("update")),
+		cb.Update().After("gorm:update").Register("mgin:trace_after_update", p.after),
+		cb.Delete().Before("gorm:delete").Register("mgin:trace_before_delete", p.before("delete")),
+		cb.Delete().After("gorm:delete").Register("mgin:trace_after_delete", p.after),
+		cb.Row().Before("gorm:row").Register("mgin:trace_before_row", p.before("row")),
+		cb.Row().After("gorm:row").Register("mgin:trace_after_row", p.after),
+		cb.Raw().Before("gorm:raw").Register("mgin:trace_before_raw", p.before("raw")),
+		cb.Raw().After("gorm:raw").Register("mgin:trace_after_raw", p.after),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tracePlugin) before(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if !tracing.Enabled() || db.Statement.Context == nil {
+			return
+		}
+		_, span := tracing.Start(db.Statement.Context, "mysql."+op, trace.SpanKindClient,
+			semconv.DBSystemMySQL,
+			semconv.DBOperationKey.String(op),
+			semconv.DBSQLTableKey.String(db.Statement.Table),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func (tracePlugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	span.SetAttributes(
+		semconv.DBStatementKey.String(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	tracing.End(span, err)
+}
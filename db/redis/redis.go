@@ -1,6 +1,7 @@
 package redis
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/go-redis/redis"
@@ -8,6 +9,7 @@ import (
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/rawbytes"
 	"github.com/levigross/grequests"
+	"github.com/maczh/mgin/tracing"
 	"github.com/sadlil/gologger"
 	"net"
 	"strings"
@@ -215,6 +217,20 @@ func (r *RedisClient) GetConnection(dbName ...string) (*redis.Client, error) {
 	}
 }
 
+// GetConnectionContext 获取在ctx中执行命令的连接，开启链路追踪时为每个命令创建span
+func (r *RedisClient) GetConnectionContext(ctx context.Context, dbName ...string) (*redis.Client, error) {
+	rc, err := r.GetConnection(dbName...)
+	if err != nil {
+		return nil, err
+	}
+	rc = rc.WithContext(ctx)
+	if tracing.Enabled() {
+		rc.WrapProcess(traceProcess(ctx, rc.Options().DB))
+		rc.WrapProcessPipeline(traceProcessPipeline(ctx, rc.Options().DB))
+	}
+	return rc, nil
+}
+
 func (r *RedisClient) IsMultiDB() bool {
 	return r.multi
 }
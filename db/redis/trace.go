@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"github.com/go-redis/redis"
+	"github.com/maczh/mgin/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+// traceProcess 为每个redis命令创建span，只记录命令名，不记录参数
+func traceProcess(ctx context.Context, db int) func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+	return func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			_, span := tracing.Start(ctx, "redis."+cmd.Name(), trace.SpanKindClient,
+				semconv.DBSystemRedis,
+				semconv.DBOperationKey.String(cmd.Name()),
+				semconv.DBRedisDBIndexKey.Int(db),
+			)
+			err := old(cmd)
+			if err == redis.Nil {
+				tracing.End(span, nil)
+			} else {
+				tracing.End(span, err)
+			}
+			return err
+		}
+	}
+}
+
+// traceProcessPipeline 为每次pipeline创建一个span
+func traceProcessPipeline(ctx context.Context, db int) func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+	return func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			names := make([]string, 0, len(cmds))
+			for _, cmd := range cmds {
+				names = append(names, cmd.Name())
+			}
+			_, span := tracing.Start(ctx, "redis.pipeline", trace.SpanKindClient,
+				semconv.DBSystemRedis,
+				semconv.DBOperationKey.String(strings.Join(names, " ")),
+				semconv.DBRedisDBIndexKey.Int(db),
+				attribute.Int("db.redis.num_cmd", len(cmds)),
+			)
+			err := old(cmds)
+			if err == redis.Nil {
+				tracing.End(span, nil)
+			} else {
+				tracing.End(span, err)
+			}
+			return err
+		}
+	}
+}
@@ -15,3 +15,23 @@ const (
 	Success            = "success"
 	DbQueryErr         = "数据库查询失败"
 )
+
+const (
+	SignatureLost   = "签名参数缺失"
+	SignatureError  = "签名校验失败"
+	RequestExpired  = "请求已过期"
+	RequestReplayed = "重复的请求"
+)
+
+const (
+	CallerUnauthorized = "调用方未授权"
+)
+
+// Translations 框架内置消息的翻译，多语言服务中未配置时使用，键为语言
+var Translations = map[string]map[string]string{
+	SignatureLost:      {"en-us": "Signature parameters missing"},
+	SignatureError:     {"en-us": "Signature verification failed"},
+	RequestExpired:     {"en-us": "Request expired"},
+	RequestReplayed:    {"en-us": "Duplicate request"},
+	CallerUnauthorized: {"en-us": "Caller not authorized"},
+}
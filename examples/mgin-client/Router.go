@@ -37,7 +37,7 @@ func setupRouter() *gin.Engine {
 	//engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	//处理全局异常
-	engine.Use(nice.Recovery(recoveryHandler))
+	engine.Use(nice.Recovery(postlog.RecoveryHandler(recoveryHandler)))
 
 	//设置404返回的内容
 	engine.NoRoute(func(c *gin.Context) {
@@ -0,0 +1,91 @@
+// Code generated by mgin-gen. DO NOT EDIT.
+
+package mgclient
+
+import (
+	"context"
+	"github.com/maczh/mgin/client"
+	"github.com/maczh/mgin/models"
+	"github.com/maczh/mgin/utils"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const ServiceMginServer = "mgin-server"
+
+const (
+	UriMginServerUserInsert = "/api/v1/user/add"
+	UriMginServerUserQuery  = "/api/v1/user/get"
+)
+
+type mginServerClient struct{}
+
+// MginServer mgin-server服务的客户端
+var MginServer = &mginServerClient{}
+
+// MginServerUser 对应服务端的model.User
+type MginServerUser struct {
+	Id       bson.ObjectId `json:"id" bson:"_id"`
+	Name     string        `json:"name" bson:"name"`
+	Age      int           `json:"age" bson:"age"`
+	JobTitle string        `json:"jobTitle" bson:"jobTitle"`
+}
+
+// UserInsertResult UserInsert的返回结果
+type UserInsertResult struct {
+	Status int                `json:"status"`
+	Msg    string             `json:"msg"`
+	Data   MginServerUser     `json:"data"`
+	Page   *models.ResultPage `json:"page"`
+}
+
+// UserInsert 添加用户
+// POST /api/v1/user/add
+func (c *mginServerClient) UserInsert(ctx context.Context, body MginServerUser, header ...map[string]string) UserInsertResult {
+	headers := mginServerHeader(header)
+	resp, err := client.RestfulWithContext(ctx, "POST", ServiceMginServer, UriMginServerUserInsert, nil, nil, headers, body)
+	if err != nil {
+		return UserInsertResult{Status: client.ErrorCode(err), Msg: err.Error()}
+	}
+	var result UserInsertResult
+	utils.FromJSON(resp, &result)
+	return result
+}
+
+// UserQueryRequest UserQuery的请求参数
+type UserQueryRequest struct {
+	Name string `json:"name"` //姓名
+}
+
+// UserQueryResult UserQuery的返回结果
+type UserQueryResult struct {
+	Status int                `json:"status"`
+	Msg    string             `json:"msg"`
+	Data   []MginServerUser   `json:"data"`
+	Page   *models.ResultPage `json:"page"`
+}
+
+// UserQuery 按姓名查询用户
+// GET /api/v1/user/get
+func (c *mginServerClient) UserQuery(ctx context.Context, req UserQueryRequest, header ...map[string]string) UserQueryResult {
+	headers := mginServerHeader(header)
+	query := make(map[string]string)
+	query["name"] = req.Name
+	resp, err := client.RestfulWithContext(ctx, "GET", ServiceMginServer, UriMginServerUserQuery, nil, query, headers, nil)
+	if err != nil {
+		return UserQueryResult{Status: client.ErrorCode(err), Msg: err.Error()}
+	}
+	var result UserQueryResult
+	utils.FromJSON(resp, &result)
+	return result
+}
+
+// mginServerHeader 合并调用时传入的header
+func mginServerHeader(header []map[string]string) map[string]string {
+	headers := make(map[string]string)
+	for _, h := range header {
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	return headers
+}
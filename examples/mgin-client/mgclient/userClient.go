@@ -1,3 +1,5 @@
+//go:generate go run github.com/maczh/mgin/cmd/mgin-gen -dir ../../mgin-server -service mgin-server -out mgin_server.go
+
 package mgclient
 
 import (
@@ -20,7 +22,7 @@ var User = &user{}
 func (u *user) Add(userInfo model.User) models.Result {
 	resp, err := client.Nacos.Call(ServiceExample, UriUserAdd, "POST", userInfo)
 	if err != nil {
-		return models.Error(-1, err.Error())
+		return models.Error(client.ErrorCode(err), err.Error())
 	}
 	var result models.Result
 	utils.FromJSON(resp, &result)
@@ -30,7 +32,7 @@ func (u *user) Add(userInfo model.User) models.Result {
 func (u *user) Get(param map[string]string) models.Result {
 	resp, err := client.Nacos.Call(ServiceExample, UriUserQuery, "GET", nil, param)
 	if err != nil {
-		return models.Error(-1, err.Error())
+		return models.Error(client.ErrorCode(err), err.Error())
 	}
 	var result models.Result
 	utils.FromJSON(resp, &result)
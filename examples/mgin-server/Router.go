@@ -39,11 +39,11 @@ func setupRouter() *gin.Engine {
 	//engine.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	//处理全局异常
-	engine.Use(nice.Recovery(recoveryHandler))
+	engine.Use(nice.Recovery(postlog.RecoveryHandler(recoveryHandler)))
 
 	//设置404返回的内容
 	engine.NoRoute(func(c *gin.Context) {
-		c.JSON(http.StatusOK, i18n.Error(errcode.URI_NOT_FOUND, "404"))
+		c.JSON(http.StatusOK, i18n.ErrorFrom(c, errcode.URI_NOT_FOUND, "404"))
 	})
 
 	var result models.Result
@@ -63,5 +63,5 @@ func setupRouter() *gin.Engine {
 }
 
 func recoveryHandler(c *gin.Context, err interface{}) {
-	c.JSON(http.StatusOK, i18n.Error(errcode.SYSTEM_ERROR, "系统异常"))
+	c.JSON(http.StatusOK, i18n.ErrorFrom(c, errcode.SYSTEM_ERROR, "系统异常"))
 }
@@ -13,6 +13,14 @@ type userController struct{}
 
 var User = &userController{}
 
+// Insert	godoc
+// @Summary		添加用户
+// @Tags	用户
+// @Accept	json
+// @Produce json
+// @Param	user body model.User true "用户信息"
+// @Success 200 {object} models.Result{data=model.User}
+// @Router	/api/v1/user/add [post]
 func (u *userController) Insert(c *gin.Context) models.Result {
 	var user model.User
 	err := c.BindJSON(&user)
@@ -23,6 +31,13 @@ func (u *userController) Insert(c *gin.Context) models.Result {
 	return service.User.Add(user)
 }
 
+// Query	godoc
+// @Summary		按姓名查询用户
+// @Tags	用户
+// @Produce json
+// @Param	name query string true "姓名"
+// @Success 200 {object} models.Result{data=[]model.User}
+// @Router	/api/v1/user/get [get]
 func (u *userController) Query(params map[string]string) models.Result {
 	if rs := i18n.CheckParametersLost(params, "name"); rs.Status != 1 {
 		return rs
@@ -85,9 +85,7 @@ func main() {
 	//https端口侦听
 	if config.Config.App.Cert != "" {
 		go func() {
-			var err error
-			path, _ := filepath.Abs(filepath.Dir(os.Args[0]))
-			err = serverSsl.ListenAndServeTLS(path+"/"+config.Config.App.Cert, path+"/"+config.Config.App.Key)
+			err := mgin.MGin.ListenAndServeTLS(serverSsl)
 			if err != nil && err != http.ErrServerClosed {
 				logs.Error("HTTPS server listen: {}", err.Error())
 			}
@@ -0,0 +1,107 @@
+package mgin
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/middleware/trace"
+	"github.com/maczh/mgin/middleware/xlang"
+	"github.com/maczh/mgin/scope"
+	"runtime/debug"
+	"sync"
+)
+
+// Go 在新协程中执行fn，fn的ctx携带请求作用域(请求id、请求头、语言与链路信息)，panic时记录日志与堆栈
+// ctx可直接传入*gin.Context，新协程不随请求结束而取消
+// go.application.routine_cache不为false时请求作用域同时写入新协程的缓存
+//
+//	mgin.Go(c, func(ctx context.Context) {
+//		logs.WithContext(ctx).Info("后台处理")
+//		client.Nacos.WithContext(ctx).Call(...)
+//	})
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	ctx = detach(ctx)
+	go run(ctx, fn)
+}
+
+// Pool 限制并发数的协程池，任务的ctx与Go相同
+type Pool struct {
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+// NewPool 创建协程池，size为最大并发数，小于等于0时不限制
+func NewPool(size int) *Pool {
+	p := &Pool{}
+	if size > 0 {
+		p.sem = make(chan struct{}, size)
+	}
+	return p
+}
+
+// Go 提交任务，并发数已满时阻塞等待，等待期间ctx取消则放弃提交并返回ctx的错误
+func (p *Pool) Go(ctx context.Context, fn func(ctx context.Context)) error {
+	if p.sem != nil {
+		rc := requestContext(ctx)
+		select {
+		case p.sem <- struct{}{}:
+		case <-rc.Done():
+			return rc.Err()
+		}
+	}
+	p.wg.Add(1)
+	ctx = detach(ctx)
+	go func() {
+		defer p.wg.Done()
+		if p.sem != nil {
+			defer func() { <-p.sem }()
+		}
+		run(ctx, fn)
+	}()
+	return nil
+}
+
+// Wait 等待已提交的任务全部完成
+func (p *Pool) Wait() {
+	p.wg.Wait()
+}
+
+// run 执行fn，panic时记录日志与堆栈
+func run(ctx context.Context, fn func(ctx context.Context)) {
+	defer func() {
+		if err := recover(); err != nil {
+			logs.WithContext(ctx).Error("协程异常:{}\n{}", fmt.Sprint(err), string(debug.Stack()))
+		}
+	}()
+	if config.Current().App.RoutineCache {
+		defer trace.BindRoutine(ctx)()
+		defer xlang.BindRoutine(ctx)()
+	}
+	fn(ctx)
+}
+
+// RoutineCache 将请求作用域保存到处理请求的协程的缓存，兼容按协程id获取请求id与语言的旧代码，须在TraceId与RequestLanguage之后使用
+// 用于go.application.routine_cache为false时只对部分路由开启
+//
+// Deprecated: 每个请求都需获取协程id，请通过ctx获取请求作用域，如logs.WithContext(c)、i18n.StringFrom(c, id)
+func RoutineCache() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer trace.BindRoutine(c)()
+		defer xlang.BindRoutine(c)()
+		c.Next()
+	}
+}
+
+func requestContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return scope.RequestContext(ctx)
+}
+
+// detach 保留ctx中的请求作用域与链路信息，不继承超时与取消
+func detach(ctx context.Context) context.Context {
+	return scope.Detach(ctx)
+}
@@ -1,6 +1,7 @@
 package i18n
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/maczh/mgin/cache"
@@ -20,7 +21,7 @@ var appName, defaultLanguage string
 func Init() {
 	appName = config.Config.GetConfigString("go.xlang.appName")
 	if appName == "" {
-		appName = config.Config.App.Name
+		appName = config.Current().App.Name
 	}
 	defaultLanguage = config.Config.GetConfigString("go.xlang.default")
 	if defaultLanguage == "" {
@@ -70,6 +71,10 @@ func GetXLangString(stringId, lang string) string {
 	if ok {
 		return str.(string)
 	}
+	//框架内置消息的翻译
+	if t, ok := errcode.Translations[stringId][lang]; ok {
+		return t
+	}
 	key = fmt.Sprintf("%s:%s", stringId, defaultLanguage)
 	str, ok = cache.OnGetCache("x-lang").Value(key)
 	if ok {
@@ -96,6 +101,11 @@ func Error(code int, messageId string) models.Result {
 	return models.Error(code, String(messageId))
 }
 
+// ErrorFrom 按ctx中请求的语言返回错误结果，可直接传入*gin.Context
+func ErrorFrom(ctx context.Context, code int, messageId string) models.Result {
+	return models.Error(code, StringFrom(ctx, messageId))
+}
+
 func ErrorWithMsg(code int, messageId, msg string) models.Result {
 	return models.Error(code, fmt.Sprintf("%s:%s", String(messageId), msg))
 }
@@ -104,6 +114,11 @@ func Success(data interface{}) models.Result {
 	return models.SuccessWithMsg(String("success"), data)
 }
 
+// SuccessFrom 按ctx中请求的语言返回成功结果，可直接传入*gin.Context
+func SuccessFrom(ctx context.Context, data interface{}) models.Result {
+	return models.SuccessWithMsg(StringFrom(ctx, "success"), data)
+}
+
 func SuccessWithPage(data interface{}, count, index, size, total int) models.Result {
 	return models.Result{
 		Status: 1,
@@ -119,8 +134,18 @@ func SuccessWithPage(data interface{}, count, index, size, total int) models.Res
 }
 
 //String 将messageId根据当前协程X-Lang参数转换成当前语言字符串
+//
+// Deprecated: 按协程id获取语言，请使用StringFrom(ctx, messageId)
 func String(messageId string) string {
-	lang := xlang.GetCurrentLanguage()
+	return langString(xlang.GetCurrentLanguage(), messageId)
+}
+
+// StringFrom 将messageId按ctx中请求的语言转换成字符串，可直接传入*gin.Context
+func StringFrom(ctx context.Context, messageId string) string {
+	return langString(xlang.GetLanguageFrom(ctx), messageId)
+}
+
+func langString(lang, messageId string) string {
 	s := GetXLangString(messageId, lang)
 	if s != "" {
 		return s
@@ -0,0 +1,117 @@
+package logs
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// OverflowBlock 队列已满时等待，不丢失日志
+	OverflowBlock = "block"
+	// OverflowDrop 队列已满时丢弃日志，不阻塞业务
+	OverflowDrop = "drop"
+
+	defaultBuffer = 8192
+	// 退出时等待队列中的日志输出的最长时间
+	flushTimeout = 5 * time.Second
+)
+
+// asyncEntry 队列中的日志，调用位置与时间在调用方协程中获取
+type asyncEntry struct {
+	log     LogInstance
+	info    *callerInfo
+	time    time.Time
+	flushed chan struct{}
+}
+
+var (
+	asyncQueue atomic.Value
+	asyncOnce  sync.Once
+	dropped    sync.Map
+	reported   = make(map[string]uint64)
+)
+
+// asyncOutput 开启异步输出时将日志放入队列，队列大小在首次开启时确定，修改后需重启生效
+func asyncOutput(log LogInstance, info *callerInfo, t time.Time) bool {
+	s := current()
+	if !s.async {
+		return false
+	}
+	asyncOnce.Do(func() {
+		q := make(chan asyncEntry, s.buffer)
+		asyncQueue.Store(q)
+		go asyncLoop(q)
+	})
+	q := asyncQueue.Load().(chan asyncEntry)
+	e := asyncEntry{log: log, info: info, time: t}
+	if s.overflow == OverflowDrop {
+		select {
+		case q <- e:
+		default:
+			counter, _ := dropped.LoadOrStore(log.LogType, new(uint64))
+			atomic.AddUint64(counter.(*uint64), 1)
+		}
+		return true
+	}
+	q <- e
+	return true
+}
+
+func asyncLoop(q chan asyncEntry) {
+	for e := range q {
+		if e.flushed != nil {
+			flushFiles()
+			close(e.flushed)
+			continue
+		}
+		reportDropped()
+		logPrint(e.log, e.info, e.time)
+	}
+}
+
+// reportDropped 有新丢弃的日志时输出一条警告
+func reportDropped() {
+	var n uint64
+	dropped.Range(func(key, value interface{}) bool {
+		total := atomic.LoadUint64(value.(*uint64))
+		n += total - reported[key.(string)]
+		reported[key.(string)] = total
+		return true
+	})
+	if n == 0 {
+		return
+	}
+	info := &callerInfo{packageName: "github.com/maczh/mgin/logs", fileName: "async.go", funcName: "asyncLoop"}
+	for _, l := range current().logger.Loggers {
+		logPrint(LogInstance{LogType: "WRN", Message: fmt.Sprintf("日志队列已满，丢弃了%d条日志", n), LoggerInit: l}, info, time.Now())
+	}
+}
+
+// Dropped 获取异步输出时因队列已满丢弃的日志数，按日志类型(DBG、INF、WRN、ERR等)统计
+func Dropped() map[string]uint64 {
+	counts := make(map[string]uint64)
+	dropped.Range(func(key, value interface{}) bool {
+		counts[key.(string)] = atomic.LoadUint64(value.(*uint64))
+		return true
+	})
+	return counts
+}
+
+// Flush 等待队列中的日志输出完成，发送到外部日志存储并写入文件，退出前调用
+func Flush() {
+	if q, ok := asyncQueue.Load().(chan asyncEntry); ok {
+		done := make(chan struct{})
+		select {
+		case q <- asyncEntry{flushed: done}:
+			select {
+			case <-done:
+			case <-time.After(flushTimeout):
+			}
+		case <-time.After(flushTimeout):
+		}
+	}
+	flushSinks()
+	flushFiles()
+}
@@ -0,0 +1,82 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+var logTypes = map[string]string{
+	"debug": "DBG",
+	"info":  "INF",
+	"warn":  "WRN",
+	"error": "ERR",
+}
+
+// LogDepth 按级别(debug、info、warn、error)输出日志，用于桥接其他日志库
+// ctx中有请求作用域时使用其请求id，否则使用当前协程的请求id，ctx可为nil
+// depth为日志调用位置相对LogDepth调用方的层数，0为调用方本身
+func LogDepth(ctx context.Context, depth int, level, msg string, fields ...Field) {
+	var pcs [1]uintptr
+	runtime.Callers(depth+2, pcs[:])
+	logPC(ctx, pcs[0], level, msg, fields, true)
+}
+
+// logPC 按pc对应的调用位置输出日志，日志级别按调用位置所在的包判断，toSink为false时不发送到外部日志存储
+func logPC(ctx context.Context, pc uintptr, level, msg string, fields []Field, toSink bool) {
+	s := current()
+	info := pcCallerInfo(pc)
+	if !levelAllowed(s.levelFor(info.packageName), level) {
+		return
+	}
+	requestId := requestIdFrom(ctx)
+	logType, ok := logTypes[level]
+	if !ok {
+		logType = "LOG"
+	}
+	for _, l := range s.logger.Loggers {
+		if !toSink && sinkRegistered(l.PrinterType) {
+			continue
+		}
+		emit(LogInstance{LogType: logType, Message: msg, LoggerInit: l, RequestId: requestId, Fields: fields}, info)
+	}
+}
+
+// StdLogger 以指定级别输出到logs，实现了Print、Printf、Println与Output
+// 可用于sarama.Logger、elastic.SetInfoLog/SetErrorLog、mgo.SetLogger等日志接口
+type StdLogger struct {
+	Level string
+	// NoSink 不发送到外部日志存储，用于es与kafka客户端自身的日志，避免发送日志时产生的日志再次发送
+	NoSink bool
+}
+
+// NewStdLogger 创建指定级别的StdLogger
+func NewStdLogger(level string) *StdLogger {
+	return &StdLogger{Level: level}
+}
+
+func (l *StdLogger) Print(v ...interface{}) {
+	l.log(3, fmt.Sprint(v...))
+}
+
+func (l *StdLogger) Printf(format string, v ...interface{}) {
+	l.log(3, fmt.Sprintf(format, v...))
+}
+
+func (l *StdLogger) Println(v ...interface{}) {
+	l.log(3, fmt.Sprintln(v...))
+}
+
+// Output 实现mgo的日志接口，calldepth与标准库log.Logger.Output相同
+func (l *StdLogger) Output(calldepth int, s string) error {
+	l.log(calldepth+2, s)
+	return nil
+}
+
+// log skip为调用位置相对log的层数，与runtime.Callers相同
+func (l *StdLogger) log(skip int, msg string) {
+	var pcs [1]uintptr
+	runtime.Callers(skip, pcs[:])
+	logPC(nil, pcs[0], l.Level, strings.TrimRight(msg, "\n"), nil, !l.NoSink)
+}
@@ -6,9 +6,13 @@ import (
 )
 
 func ConsolePrinter(log LogInstance, packageName string, fileName string, lineNumber int, funcName string, time time.Time) {
+	if log.LoggerInit.Format == JsonFormat {
+		fmt.Print(jsonLine(log, packageName, fileName, lineNumber, funcName, time))
+		return
+	}
 	color := getColor(log)
 	color.Set()
-	fmt.Printf("[%s] [%s] [%s::%s::%s] [%d] %s\n", log.LogType, time.Format("2006-01-02 15:04:05"), packageName, fileName, funcName, lineNumber, log.Message)
+	fmt.Printf("[%s] [%s] [%s::%s::%s] [%d] %s\n", log.LogType, time.Format("2006-01-02 15:04:05"), packageName, fileName, funcName, lineNumber, textMessage(log))
 	Unset()
 }
 
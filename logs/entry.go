@@ -0,0 +1,75 @@
+package logs
+
+import (
+	"context"
+	"github.com/maczh/mgin/middleware/trace"
+)
+
+// Entry 带有附加字段的日志，json格式时字段单独输出，文本格式时以key=value附加在消息后
+//
+//	logs.With("orderId", orderId).With("amount", amount).Info("订单支付成功")
+//	logs.WithContext(c).Info("订单支付成功")
+type Entry struct {
+	ctx    context.Context
+	fields []Field
+}
+
+// With 创建带有附加字段的日志
+func With(key string, value interface{}) *Entry {
+	return &Entry{fields: []Field{{Key: key, Value: value}}}
+}
+
+// WithContext 创建使用ctx中请求id的日志，可直接传入*gin.Context
+func WithContext(ctx context.Context) *Entry {
+	return &Entry{ctx: ctx}
+}
+
+// With 追加字段，返回新的Entry，原Entry不变
+func (e *Entry) With(key string, value interface{}) *Entry {
+	fields := make([]Field, len(e.fields), len(e.fields)+1)
+	copy(fields, e.fields)
+	return &Entry{ctx: e.ctx, fields: append(fields, Field{Key: key, Value: value})}
+}
+
+// WithContext 使用ctx中的请求id，返回新的Entry，原Entry不变
+func (e *Entry) WithContext(ctx context.Context) *Entry {
+	return &Entry{ctx: ctx, fields: e.fields}
+}
+
+// requestIdFrom 获取ctx中的请求id，ctx中没有时使用旧接口保存在协程缓存中的请求id
+func requestIdFrom(ctx context.Context) string {
+	if ctx != nil {
+		if requestId := trace.GetRequestIdFrom(ctx); requestId != "" {
+			return requestId
+		}
+	}
+	return trace.GetRequestId()
+}
+
+func (e *Entry) Debug(format string, v ...interface{}) {
+	s := current()
+	if s.enabled("debug") {
+		s.logger.output("DBG", formatMessage(format, v), requestIdFrom(e.ctx), e.fields)
+	}
+}
+
+func (e *Entry) Info(format string, v ...interface{}) {
+	s := current()
+	if s.enabled("info") {
+		s.logger.output("INF", formatMessage(format, v), requestIdFrom(e.ctx), e.fields)
+	}
+}
+
+func (e *Entry) Warn(format string, v ...interface{}) {
+	s := current()
+	if s.enabled("warn") {
+		s.logger.output("WRN", formatMessage(format, v), requestIdFrom(e.ctx), e.fields)
+	}
+}
+
+func (e *Entry) Error(format string, v ...interface{}) {
+	s := current()
+	if s.enabled("error") {
+		s.logger.output("ERR", formatMessage(format, v), requestIdFrom(e.ctx), e.fields)
+	}
+}
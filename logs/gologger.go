@@ -8,8 +8,10 @@ const (
 	CONSOLE       string = "console"
 	FILE          string = "file"
 	ELASTICSEARCH string = "es"
+	KAFKA         string = "kafka"
 	SimpleLog     string = "simple"
 	ColoredLog    string = "color"
+	JsonFormat    string = "json"
 )
 
 type GoLogger struct {
@@ -17,7 +19,8 @@ type GoLogger struct {
 }
 
 func GetLogger(selector ...string) GoLogger {
-	logFileName := config.Config.Logger.File
+	logFileName := config.Current().Logger.File
+	format := config.Current().Logger.Format
 	if len(selector) == 0 {
 		if logFileName != "" {
 			selector = []string{CONSOLE, FILE}
@@ -29,16 +32,28 @@ func GetLogger(selector ...string) GoLogger {
 	for _, sel := range selector {
 		switch sel {
 		case CONSOLE:
-			loggers = append(loggers, Logger{CONSOLE, ColoredLog})
+			loggers = append(loggers, Logger{PrinterType: CONSOLE, Location: ColoredLog, Format: format})
 		case FILE:
 			if logFileName != "" {
-				loggers = append(loggers, Logger{FILE, logFileName})
+				loggers = append(loggers, Logger{PrinterType: FILE, Location: logFileName, Format: format})
+			}
+		default:
+			//es、kafka及其他通过RegisterSink注册的外部日志存储
+			if sinkRegistered(sel) {
+				loggers = append(loggers, Logger{PrinterType: sel, Location: sel, Format: JsonFormat})
 			}
 		}
 	}
 	return GoLogger{loggers}
 }
 
+// output 输出logs包格式化后的日志，请求id与附加字段单独保存，由输出格式决定如何输出
+func (log GoLogger) output(logType, message, requestId string, fields []Field) {
+	for _, logger := range log.Loggers {
+		logPrinter(LogInstance{LogType: logType, Message: message, LoggerInit: logger, RequestId: requestId, Fields: fields})
+	}
+}
+
 func (log GoLogger) Log(message string) {
 	for _, logger := range log.Loggers {
 		logPrinter(LogInstance{LogType: "LOG", Message: message, LoggerInit: logger})
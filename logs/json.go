@@ -0,0 +1,86 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/maczh/mgin/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var jsonLevels = map[string]string{
+	"LOG": "log",
+	"MSG": "msg",
+	"INF": "info",
+	"WRN": "warn",
+	"DBG": "debug",
+	"ERR": "error",
+	"CRT": "fatal",
+	"RSS": "msg",
+}
+
+// json格式的固定字段，附加字段与之重名时加上fields.前缀
+var reservedKeys = map[string]bool{"ts": true, "level": true, "app": true, "requestId": true, "caller": true, "msg": true}
+
+// textMessage 文本格式的消息，请求id作为前缀，附加字段以key=value附加在后面
+func textMessage(log LogInstance) string {
+	msg := log.Message
+	if log.RequestId != "" {
+		msg = "[" + log.RequestId + "] " + msg
+	}
+	for _, f := range log.Fields {
+		msg += " " + f.Key + "=" + valueString(f.Value)
+	}
+	return msg
+}
+
+// jsonLine 将日志转换为一行json，字段依次为ts、level、app、requestId、caller、msg及附加字段
+func jsonLine(log LogInstance, packageName string, fileName string, lineNumber int, funcName string, time time.Time) string {
+	var buf bytes.Buffer
+	buf.WriteString(`{"ts":`)
+	writeJsonValue(&buf, time.Format("2006-01-02T15:04:05.000Z07:00"))
+	buf.WriteString(`,"level":`)
+	level, ok := jsonLevels[log.LogType]
+	if !ok {
+		level = strings.ToLower(log.LogType)
+	}
+	writeJsonValue(&buf, level)
+	buf.WriteString(`,"app":`)
+	writeJsonValue(&buf, config.Current().App.Name)
+	if log.RequestId != "" {
+		buf.WriteString(`,"requestId":`)
+		writeJsonValue(&buf, log.RequestId)
+	}
+	buf.WriteString(`,"caller":`)
+	writeJsonValue(&buf, packageName+"/"+fileName+":"+strconv.Itoa(lineNumber)+" "+funcName)
+	buf.WriteString(`,"msg":`)
+	writeJsonValue(&buf, log.Message)
+	for _, f := range log.Fields {
+		key := f.Key
+		if reservedKeys[key] {
+			key = "fields." + key
+		}
+		buf.WriteByte(',')
+		writeJsonValue(&buf, key)
+		buf.WriteByte(':')
+		writeJsonValue(&buf, f.Value)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func writeJsonValue(buf *bytes.Buffer, v interface{}) {
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		b.Reset()
+		enc.Encode(fmt.Sprint(v))
+	}
+	buf.Write(bytes.TrimRight(b.Bytes(), "\n"))
+}
@@ -0,0 +1,170 @@
+package logs
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/errcode"
+	"github.com/maczh/mgin/models"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	stateLock sync.Mutex
+	// 调用位置对应的包名
+	callerPackages sync.Map
+)
+
+var levels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// callerPackage 获取调用方的包名，如github.com/maczh/mgin/client
+func callerPackage(skip int) string {
+	pc, _, _, ok := runtime.Caller(skip)
+	if !ok {
+		return ""
+	}
+	if pkg, ok := callerPackages.Load(pc); ok {
+		return pkg.(string)
+	}
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	pkg := name
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		pkg = name[:slash+1+dot]
+	}
+	callerPackages.Store(pc, pkg)
+	return pkg
+}
+
+// levelFor 获取包的日志级别，配置的包名与包路径的末尾几段相同即匹配，包含其子包，有多个匹配时使用最长的
+func (s *loggerState) levelFor(pkg string) string {
+	level, matched := s.level, ""
+	for name, l := range s.packages {
+		if len(name) > len(matched) && packageMatch(pkg, name) {
+			level, matched = l, name
+		}
+	}
+	return level
+}
+
+func packageMatch(pkg, name string) bool {
+	return pkg == name || strings.HasSuffix(pkg, "/"+name) ||
+		strings.HasPrefix(pkg, name+"/") || strings.Contains(pkg, "/"+name+"/")
+}
+
+// SetLevel 修改全局日志级别，配置文件重新加载后恢复为配置的级别
+func SetLevel(level string) error {
+	return setLevel("", level, "SetLevel")
+}
+
+// SetPackageLevel 修改包的日志级别，level为空时删除包的单独级别，使用全局级别
+func SetPackageLevel(pkg, level string) error {
+	if pkg == "" {
+		return errors.New("包名不可为空")
+	}
+	return setLevel(pkg, level, "SetPackageLevel")
+}
+
+// Levels 获取全局日志级别与各包的日志级别
+func Levels() (string, map[string]string) {
+	s := current()
+	packages := make(map[string]string)
+	for pkg, level := range s.packages {
+		packages[pkg] = level
+	}
+	return s.level, packages
+}
+
+func setLevel(pkg, level, source string) error {
+	if !levels[level] && !(pkg != "" && level == "") {
+		return errors.New("不支持的日志级别:" + level)
+	}
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	prev := current()
+	s := *prev
+	s.packages = make(map[string]string)
+	for name, l := range prev.packages {
+		s.packages[name] = l
+	}
+	if pkg == "" {
+		s.level = level
+	} else if level == "" {
+		delete(s.packages, pkg)
+	} else {
+		s.packages[pkg] = level
+	}
+	state.Store(&s)
+	auditLevels(&s, prev, source)
+	return nil
+}
+
+// auditLevels 记录日志级别的修改，不受日志级别限制
+func auditLevels(s, prev *loggerState, source string) {
+	changes := make([]string, 0)
+	if s.level != prev.level {
+		changes = append(changes, "全局:"+prev.level+"->"+s.level)
+	}
+	names := make([]string, 0)
+	for name := range prev.packages {
+		names = append(names, name)
+	}
+	for name := range s.packages {
+		if _, ok := prev.packages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if s.packages[name] != prev.packages[name] {
+			changes = append(changes, name+":"+levelName(prev.packages[name])+"->"+levelName(s.packages[name]))
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+	s.logger.output("WRN", "日志级别已修改 "+strings.Join(changes, ", "), requestIdFrom(nil),
+		[]Field{{Key: "audit", Value: "log_level"}, {Key: "source", Value: source}})
+}
+
+func levelName(level string) string {
+	if level == "" {
+		return "默认"
+	}
+	return level
+}
+
+// LevelHandler 查看与修改日志级别的接口，接口本身不做权限校验，需配合mtls、sign等中间件使用
+//
+//	engine.GET("/admin/log/level", logs.LevelHandler())
+//	engine.POST("/admin/log/level", mtls.CallerService("admin"), logs.LevelHandler())
+//
+// GET返回全局级别与各包的级别，POST参数level修改全局级别，同时传入package时修改包的级别，level为空时删除包的级别
+func LevelHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != "GET" {
+			level := c.PostForm("level")
+			if level == "" {
+				level = c.Query("level")
+			}
+			pkg := c.PostForm("package")
+			if pkg == "" {
+				pkg = c.Query("package")
+			}
+			var err error
+			if pkg == "" {
+				err = setLevel("", level, "LevelHandler:"+c.ClientIP())
+			} else {
+				err = setLevel(pkg, level, "LevelHandler:"+c.ClientIP())
+			}
+			if err != nil {
+				c.JSON(200, models.Error(errcode.REQUEST_PARAMETER_LOST, err.Error()))
+				return
+			}
+		}
+		level, packages := Levels()
+		c.JSON(200, models.Success(gin.H{"level": level, "packages": packages}))
+	}
+}
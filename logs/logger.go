@@ -4,38 +4,90 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/maczh/mgin/config"
-	"github.com/maczh/mgin/middleware/trace"
-	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
 type Logger struct {
 	PrinterType string
 	Location    string
+	Format      string
 }
 
 type LogInstance struct {
 	LogType    string
 	Message    string
 	LoggerInit Logger
+	RequestId  string
+	Fields     []Field
 }
 
-var logger GoLogger
-var logLevel = "debug"
+// Field 通过With附加到日志中的字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// loggerState 按配置创建的日志输出与级别，配置加载后只创建一次，配置重新加载时重建
+type loggerState struct {
+	logger   GoLogger
+	level    string
+	packages map[string]string
+	async    bool
+	buffer   int
+	overflow string
+}
+
+var state atomic.Value
 
-func initConfig() {
-	l := config.Config.Logger.Out
+func init() {
+	config.Config.OnReload(func() {
+		stateLock.Lock()
+		defer stateLock.Unlock()
+		prev := current()
+		s := newState()
+		state.Store(s)
+		auditLevels(s, prev, "配置重新加载")
+	})
+}
+
+func newState() *loggerState {
+	cfg := config.Current()
+	s := &loggerState{level: "debug", buffer: defaultBuffer, overflow: OverflowBlock}
+	l := cfg.Logger.Out
 	if l != "" {
-		loggers := strings.Split(l, ",")
-		logger = GetLogger(loggers...)
+		s.logger = GetLogger(strings.Split(l, ",")...)
 	} else {
-		logger = GetLogger()
+		s.logger = GetLogger()
+	}
+	if cfg.Logger.Level != "" {
+		s.level = cfg.Logger.Level
+	}
+	s.packages = make(map[string]string)
+	for pkg, level := range cfg.Logger.Levels {
+		s.packages[pkg] = level
 	}
-	level := config.Config.Logger.Level
-	if level != "" {
-		logLevel = level
+	s.async = cfg.Logger.Async
+	if cfg.Logger.Buffer > 0 {
+		s.buffer = cfg.Logger.Buffer
 	}
+	if cfg.Logger.Overflow == OverflowDrop {
+		s.overflow = OverflowDrop
+	}
+	return s
+}
+
+// current 获取当前的日志配置，配置文件加载前每次按当前配置创建
+func current() *loggerState {
+	if s, ok := state.Load().(*loggerState); ok {
+		return s
+	}
+	s := newState()
+	if config.Current().Cnf != nil {
+		state.Store(s)
+	}
+	return s
 }
 
 func toJSON(o interface{}) string {
@@ -52,59 +104,88 @@ func toJSON(o interface{}) string {
 }
 
 func OutPrint(format string, v []interface{}) string {
-	for _, value := range v {
-		str := ""
-		switch value.(type) {
-		case bool:
-			str = strconv.FormatBool(value.(bool))
-		case float32, float64:
-			str = fmt.Sprintf("%.6f", value)
-		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
-			str = fmt.Sprintf("%d", value)
-		case string:
-			str = value.(string)
-		case []byte:
-			str = string(value.([]byte))
-		case reflect.Value:
-			str = toJSON(value)
-		default:
-			str = toJSON(value)
-		}
-		format = strings.Replace(format, "{}", str, 1)
-	}
-	traceId := trace.GetRequestId()
+	format = formatMessage(format, v)
+	traceId := requestIdFrom(nil)
 	if traceId != "" {
 		format = "[" + traceId + "] " + format
 	}
 	return format
 }
 
-func Debug(format string, v ...interface{}) {
-	initConfig()
-	switch logLevel {
+// formatMessage 依次将参数替换到format中的{}
+func formatMessage(format string, v []interface{}) string {
+	for _, value := range v {
+		format = strings.Replace(format, "{}", valueString(value), 1)
+	}
+	return format
+}
+
+func valueString(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return strconv.FormatBool(value.(bool))
+	case float32, float64:
+		return fmt.Sprintf("%.6f", value)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return fmt.Sprintf("%d", value)
+	case string:
+		return value.(string)
+	case []byte:
+		return string(value.([]byte))
+	case error:
+		return value.(error).Error()
+	default:
+		return toJSON(value)
+	}
+}
+
+// enabled 按调用方所在包的日志级别判断是否输出，没有单独配置时使用全局级别
+func (s *loggerState) enabled(level string) bool {
+	l := s.level
+	if len(s.packages) > 0 {
+		l = s.levelFor(callerPackage(3))
+	}
+	return levelAllowed(l, level)
+}
+
+func levelAllowed(setting, level string) bool {
+	switch setting {
 	case "debug":
-		logger.Debug(OutPrint(format, v))
+		return true
+	case "info":
+		return level != "debug"
+	case "warn":
+		return level == "warn" || level == "error"
+	case "error":
+		return level == "error"
+	}
+	return false
+}
+
+func Debug(format string, v ...interface{}) {
+	s := current()
+	if s.enabled("debug") {
+		s.logger.output("DBG", formatMessage(format, v), requestIdFrom(nil), nil)
 	}
 }
 
 func Info(format string, v ...interface{}) {
-	initConfig()
-	switch logLevel {
-	case "debug", "info":
-		logger.Info(OutPrint(format, v))
+	s := current()
+	if s.enabled("info") {
+		s.logger.output("INF", formatMessage(format, v), requestIdFrom(nil), nil)
 	}
 }
+
 func Warn(format string, v ...interface{}) {
-	initConfig()
-	switch logLevel {
-	case "debug", "info", "warn":
-		logger.Warn(OutPrint(format, v))
+	s := current()
+	if s.enabled("warn") {
+		s.logger.output("WRN", formatMessage(format, v), requestIdFrom(nil), nil)
 	}
 }
+
 func Error(format string, v ...interface{}) {
-	initConfig()
-	switch logLevel {
-	case "debug", "info", "warn", "error":
-		logger.Error(OutPrint(format, v))
+	s := current()
+	if s.enabled("error") {
+		s.logger.output("ERR", formatMessage(format, v), requestIdFrom(nil), nil)
 	}
 }
@@ -10,5 +10,7 @@ func Print(log LogInstance, packageName string, fileName string, lineNumber int,
 		ConsolePrinter(log, packageName, fileName, lineNumber, funcName, time)
 	case "file":
 		FilePrinter(log, packageName, fileName, lineNumber, funcName, time)
+	default:
+		SinkPrinter(log, packageName, fileName, lineNumber, funcName, time)
 	}
 }
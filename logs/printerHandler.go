@@ -9,14 +9,28 @@ import (
 )
 
 func logPrinter(log LogInstance) {
-	info := retrieveCallInfo()
+	emit(log, retrieveCallInfo())
+}
+
+// emit 脱敏后按同步或异步方式输出
+func emit(log LogInstance, info *callerInfo) {
 	timer := time.Now()
+	if masker != nil {
+		log = maskLog(log)
+	}
+	if log.LogType == "CRT" {
+		//致命错误同步输出，之前队列中的日志先输出
+		Flush()
+	} else if asyncOutput(log, info, timer) {
+		return
+	}
 	logPrint(log, info, timer)
 }
 
 func logPrint(log LogInstance, info *callerInfo, time time.Time) {
 	Print(log, info.packageName, info.fileName, info.line, info.funcName, time)
 	if log.LogType == "CRT" {
+		Flush()
 		os.Exit(1)
 	}
 }
@@ -30,8 +44,21 @@ type callerInfo struct {
 
 func retrieveCallInfo() *callerInfo {
 	pc, file, line, _ := runtime.Caller(4)
+	return newCallerInfo(runtime.FuncForPC(pc).Name(), file, line)
+}
+
+// pcCallerInfo 按调用位置的pc获取调用信息，用于桥接其他日志库
+func pcCallerInfo(pc uintptr) *callerInfo {
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.Function == "" {
+		return &callerInfo{packageName: "unknown", fileName: "unknown", funcName: "unknown"}
+	}
+	return newCallerInfo(frame.Function, frame.File, frame.Line)
+}
+
+func newCallerInfo(function, file string, line int) *callerInfo {
 	_, fileName := path.Split(file)
-	parts := strings.Split(runtime.FuncForPC(pc).Name(), ".")
+	parts := strings.Split(function, ".")
 	pl := len(parts)
 	packageName := ""
 	funcName := parts[pl-1]
@@ -50,3 +77,28 @@ func retrieveCallInfo() *callerInfo {
 		line:        line,
 	}
 }
+
+// Masker 日志脱敏，由mask包注册
+type Masker interface {
+	Text(s string) string
+	Field(name string, v interface{}) interface{}
+}
+
+var masker Masker
+
+// SetMasker 设置日志脱敏，对日志内容与With附加的字段脱敏
+func SetMasker(m Masker) {
+	masker = m
+}
+
+func maskLog(log LogInstance) LogInstance {
+	log.Message = masker.Text(log.Message)
+	if len(log.Fields) > 0 {
+		fields := make([]Field, len(log.Fields))
+		for i, f := range log.Fields {
+			fields[i] = Field{Key: f.Key, Value: masker.Field(f.Key, f.Value)}
+		}
+		log.Fields = fields
+	}
+	return log
+}
@@ -0,0 +1,265 @@
+package logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"github.com/maczh/mgin/config"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileWriter 日志文件写入，文件常驻打开并带缓冲，按天与大小切分
+// 切分后的文件按配置压缩，并按保留天数与保留数量清理
+type fileWriter struct {
+	mu       sync.Mutex
+	location string
+	file     *os.File
+	buf      *bufio.Writer
+	day      string
+	size     int64
+	failed   bool
+	millMu   sync.Mutex
+}
+
+var (
+	fileWriters   = make(map[string]*fileWriter)
+	fileWritersMu sync.Mutex
+	flushOnce     sync.Once
+)
+
+// 缓冲中的日志定时写入文件
+const flushInterval = time.Second
+
+func getFileWriter(location string) *fileWriter {
+	fileWritersMu.Lock()
+	defer fileWritersMu.Unlock()
+	w, ok := fileWriters[location]
+	if !ok {
+		w = &fileWriter{location: location}
+		fileWriters[location] = w
+	}
+	flushOnce.Do(func() {
+		go func() {
+			for range time.Tick(flushInterval) {
+				flushFiles()
+			}
+		}()
+	})
+	return w
+}
+
+// flushFiles 将文件缓冲中的日志写入文件
+func flushFiles() {
+	fileWritersMu.Lock()
+	writers := make([]*fileWriter, 0, len(fileWriters))
+	for _, w := range fileWriters {
+		writers = append(writers, w)
+	}
+	fileWritersMu.Unlock()
+	for _, w := range writers {
+		w.flush()
+	}
+}
+
+func (w *fileWriter) fileName(day string) string {
+	return w.location + "." + day + ".log"
+}
+
+// write 写入一行日志，写入失败时输出到标准错误，不影响程序运行
+func (w *fileWriter) write(t time.Time, line string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	day := t.Format("2006-01-02")
+	if w.file != nil && day != w.day {
+		w.rotate(false)
+	}
+	maxSize := int64(config.Current().Logger.MaxSize) * 1024 * 1024
+	if w.file != nil && maxSize > 0 && w.size > 0 && w.size+int64(len(line)) > maxSize {
+		w.rotate(true)
+	}
+	if w.file == nil {
+		if err := w.open(day); err != nil {
+			w.fail(err)
+			os.Stderr.WriteString(line)
+			return
+		}
+	}
+	n, err := w.buf.WriteString(line)
+	w.size += int64(n)
+	if err != nil {
+		w.fail(err)
+		w.close()
+		return
+	}
+	w.failed = false
+}
+
+func (w *fileWriter) open(day string) error {
+	name := w.fileName(day)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	w.size = 0
+	if info, err := file.Stat(); err == nil {
+		w.size = info.Size()
+	}
+	w.file = file
+	w.buf = bufio.NewWriterSize(file, 64*1024)
+	w.day = day
+	return nil
+}
+
+func (w *fileWriter) close() {
+	if w.file == nil {
+		return
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.fail(err)
+	}
+	w.file.Close()
+	w.file = nil
+	w.buf = nil
+}
+
+func (w *fileWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf == nil {
+		return
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.fail(err)
+		w.close()
+	}
+}
+
+// fail 连续失败时只提示一次，避免刷屏
+func (w *fileWriter) fail(err error) {
+	if !w.failed {
+		fmt.Fprintln(os.Stderr, "日志文件写入失败:", err)
+		w.failed = true
+	}
+}
+
+// rotate 关闭当前文件，按大小切分时将其改名为带序号的文件，之后在后台压缩与清理
+func (w *fileWriter) rotate(bySize bool) {
+	name := w.file.Name()
+	w.close()
+	if bySize {
+		rotated := nextRotateName(name)
+		if err := os.Rename(name, rotated); err != nil {
+			w.fail(err)
+			return
+		}
+		name = rotated
+	}
+	go w.mill(name)
+}
+
+// nextRotateName 按大小切分的文件名，序号在已有文件的最大序号上递增，如myapp.2006-01-02.1.log
+func nextRotateName(name string) string {
+	prefix := strings.TrimSuffix(name, ".log")
+	next := 1
+	names, _ := filepath.Glob(prefix + ".*.log*")
+	for _, n := range names {
+		index := strings.TrimPrefix(n, prefix+".")
+		index = index[:strings.Index(index, ".log")]
+		if i, err := strconv.Atoi(index); err == nil && i >= next {
+			next = i + 1
+		}
+	}
+	return prefix + "." + strconv.Itoa(next) + ".log"
+}
+
+// mill 压缩切分出的文件并清理过期的日志文件
+func (w *fileWriter) mill(rotated string) {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+	if config.Current().Logger.Compress {
+		if err := gzipFile(rotated); err != nil {
+			fmt.Fprintln(os.Stderr, "日志文件压缩失败:", err)
+		}
+	}
+	w.cleanup()
+}
+
+// cleanup 删除超过保留天数的日志文件，以及超过保留数量的最早的日志文件，当前写入的文件不删除
+func (w *fileWriter) cleanup() {
+	maxAge := config.Current().Logger.MaxAge
+	maxFiles := config.Current().Logger.MaxFiles
+	if maxAge <= 0 && maxFiles <= 0 {
+		return
+	}
+	names, err := filepath.Glob(w.location + ".*.log*")
+	if err != nil {
+		return
+	}
+	w.mu.Lock()
+	current := ""
+	if w.file != nil {
+		current = w.file.Name()
+	}
+	w.mu.Unlock()
+	type logFile struct {
+		name    string
+		modTime time.Time
+	}
+	files := make([]logFile, 0, len(names))
+	for _, name := range names {
+		if name == current {
+			continue
+		}
+		if info, err := os.Stat(name); err == nil && !info.IsDir() {
+			files = append(files, logFile{name, info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	cutoff := time.Now().AddDate(0, 0, -maxAge)
+	for i, f := range files {
+		//保留数量包含当前写入的文件
+		if (maxAge > 0 && f.modTime.Before(cutoff)) || (maxFiles > 0 && i+1 >= maxFiles) {
+			os.Remove(f.name)
+		}
+	}
+}
+
+// gzipFile 压缩为.gz文件后删除原文件
+// utils依赖logs包，这里不能使用utils.Compress，并且按流压缩避免将整个文件读入内存
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(name)
+}
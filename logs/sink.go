@@ -0,0 +1,167 @@
+package logs
+
+import (
+	"fmt"
+	"github.com/maczh/mgin/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink 外部日志存储，每条日志为一个json文档，由db包注册es与kafka
+// Sink内部不能再调用logs输出日志，否则会循环写入
+type Sink interface {
+	Write(docs []string) error
+}
+
+// SinkFunc 以函数实现Sink
+type SinkFunc func(docs []string) error
+
+func (f SinkFunc) Write(docs []string) error {
+	return f(docs)
+}
+
+const (
+	defaultSinkBatch    = 100
+	defaultSinkInterval = time.Second
+	// 待发送的日志超过此数量时直接写入本地文件
+	sinkQueueSize = 10000
+	// 发送失败后暂停发送的时间，期间日志直接写入本地文件
+	sinkRetryInterval = 30 * time.Second
+)
+
+// sinkBatcher 按数量与时间间隔批量发送日志，发送失败时写入本地文件
+type sinkBatcher struct {
+	name      string
+	sink      Sink
+	docs      chan string
+	flush     chan chan struct{}
+	downUntil time.Time
+}
+
+var sinks sync.Map
+
+// RegisterSink 注册外部日志存储，在go.logger.out中配置对应名称后日志批量发送到该存储
+func RegisterSink(name string, sink Sink) {
+	b := &sinkBatcher{
+		name:  name,
+		sink:  sink,
+		docs:  make(chan string, sinkQueueSize),
+		flush: make(chan chan struct{}),
+	}
+	if _, loaded := sinks.LoadOrStore(name, b); !loaded {
+		go b.loop()
+	}
+}
+
+func sinkRegistered(name string) bool {
+	_, ok := sinks.Load(name)
+	return ok
+}
+
+// SinkPrinter 将日志按json格式交给注册的外部日志存储
+func SinkPrinter(log LogInstance, packageName string, fileName string, lineNumber int, funcName string, time time.Time) {
+	b, ok := sinks.Load(log.LoggerInit.PrinterType)
+	if !ok {
+		return
+	}
+	b.(*sinkBatcher).add(strings.TrimSuffix(jsonLine(log, packageName, fileName, lineNumber, funcName, time), "\n"))
+}
+
+func (b *sinkBatcher) add(doc string) {
+	select {
+	case b.docs <- doc:
+	default:
+		b.spill([]string{doc})
+	}
+}
+
+// loop 批量大小与发送间隔每次按当前配置获取，注册时配置文件可能尚未加载
+func (b *sinkBatcher) loop() {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	var batch []string
+	lastSend := time.Now()
+	send := func() {
+		if len(batch) > 0 {
+			b.send(batch)
+			batch = nil
+		}
+		lastSend = time.Now()
+	}
+	for {
+		select {
+		case doc := <-b.docs:
+			batch = append(batch, doc)
+			if len(batch) >= sinkBatch() {
+				send()
+			}
+		case <-ticker.C:
+			if time.Since(lastSend) >= sinkInterval() {
+				send()
+			}
+		case done := <-b.flush:
+			for len(b.docs) > 0 {
+				batch = append(batch, <-b.docs)
+			}
+			send()
+			close(done)
+		}
+	}
+}
+
+func sinkBatch() int {
+	if config.Current().Logger.Sink.Batch > 0 {
+		return config.Current().Logger.Sink.Batch
+	}
+	return defaultSinkBatch
+}
+
+func sinkInterval() time.Duration {
+	if config.Current().Logger.Sink.Interval > 0 {
+		return time.Duration(config.Current().Logger.Sink.Interval) * time.Second
+	}
+	return defaultSinkInterval
+}
+
+func (b *sinkBatcher) send(batch []string) {
+	if time.Now().Before(b.downUntil) {
+		b.spill(batch)
+		return
+	}
+	if err := b.sink.Write(batch); err != nil {
+		fmt.Fprintf(os.Stderr, "日志发送到%s失败，%v后重试，期间写入本地文件:%v\n", b.name, sinkRetryInterval, err)
+		b.downUntil = time.Now().Add(sinkRetryInterval)
+		b.spill(batch)
+	}
+}
+
+// spill 写入本地文件，每行一个json文档，可在外部存储恢复后再导入
+func (b *sinkBatcher) spill(docs []string) {
+	location := config.Current().Logger.File
+	if location == "" {
+		location = filepath.Join("logs", config.Current().App.Name)
+	}
+	w := getFileWriter(location + "-" + b.name + "-spill")
+	now := time.Now()
+	for _, doc := range docs {
+		w.write(now, doc+"\n")
+	}
+}
+
+// flushSinks 发送各外部日志存储中待发送的日志
+func flushSinks() {
+	sinks.Range(func(_, value interface{}) bool {
+		done := make(chan struct{})
+		select {
+		case value.(*sinkBatcher).flush <- done:
+			select {
+			case <-done:
+			case <-time.After(flushTimeout):
+			}
+		case <-time.After(flushTimeout):
+		}
+		return true
+	})
+}
@@ -0,0 +1,93 @@
+//go:build go1.21
+
+package logs
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler 以logs输出的slog.Handler，请求id取自ctx中的请求作用域
+//
+//	slog.SetDefault(slog.New(logs.NewHandler()))
+//	slog.InfoContext(c, "用户登录", "userId", userId)
+type Handler struct {
+	fields []Field
+	group  string
+}
+
+// NewHandler 创建以logs输出的slog.Handler
+func NewHandler() *Handler {
+	return &Handler{}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	s := current()
+	//有按包配置的级别时在输出时按调用位置判断
+	return len(s.packages) > 0 || levelAllowed(s.level, slogLevel(level))
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	fields := make([]Field, len(h.fields), len(h.fields)+r.NumAttrs())
+	copy(fields, h.fields)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.group, a)
+		return true
+	})
+	logPC(ctx, r.PC, slogLevel(r.Level), r.Message, fields, true)
+	return nil
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]Field, len(h.fields), len(h.fields)+len(attrs))
+	copy(fields, h.fields)
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.group, a)
+	}
+	return &Handler{fields: fields, group: h.group}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{fields: h.fields, group: groupKey(h.group, name)}
+}
+
+// appendAttr 分组的属性以group.key作为字段名
+func appendAttr(fields []Field, group string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		g := groupKey(group, a.Key)
+		for _, ga := range a.Value.Group() {
+			fields = appendAttr(fields, g, ga)
+		}
+		return fields
+	}
+	return append(fields, Field{Key: groupKey(group, a.Key), Value: a.Value.Any()})
+}
+
+func groupKey(group, key string) string {
+	if group == "" {
+		return key
+	}
+	if key == "" {
+		return group
+	}
+	return group + "." + key
+}
+
+func slogLevel(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "debug"
+	case level < slog.LevelWarn:
+		return "info"
+	case level < slog.LevelError:
+		return "warn"
+	}
+	return "error"
+}
@@ -0,0 +1,333 @@
+// Package mask 日志脱敏，按请求头名称、字段名称、JSON路径与正则对日志、接口访问日志与微服务调用日志中的敏感信息脱敏
+// 在go.mask中配置，未配置时按默认的请求头、字段与手机号、身份证号脱敏
+package mask
+
+import (
+	"encoding/json"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/utils"
+	"github.com/sadlil/gologger"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Replacement 脱敏后的内容
+const Replacement = "******"
+
+var (
+	defaultHeaders  = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Token", "X-Api-Key"}
+	defaultFields   = []string{"password", "passwd", "pwd", "token", "accessToken", "refreshToken", "secret", "secretKey"}
+	defaultPatterns = []string{"mobile", "idcard"}
+
+	// 候选的手机号与身份证号，前后不能是字母或数字
+	digitsRegexp = regexp.MustCompile(`[0-9]+[Xx]?`)
+)
+
+type rules struct {
+	headers map[string]bool
+	fields  map[string]bool
+	paths   []string
+	mobile  bool
+	idCard  bool
+	regexps []*regexp.Regexp
+	// 文本中json格式的"字段":值
+	fieldRegexp *regexp.Regexp
+}
+
+var current atomic.Value
+
+// logs输出日志时会调用脱敏，这里不能使用logs
+var logger = gologger.GetLogger()
+
+func init() {
+	logs.SetMasker(logMasker{})
+	config.Config.OnReload(func() {
+		current.Store(newRules())
+	})
+}
+
+func getRules() *rules {
+	if r, ok := current.Load().(*rules); ok {
+		return r
+	}
+	r := newRules()
+	if config.Current().Cnf != nil {
+		current.Store(r)
+	}
+	return r
+}
+
+// newRules 按go.mask配置创建脱敏规则，headers、fields、paths在默认值的基础上增加，patterns配置后替换默认值
+func newRules() *rules {
+	r := &rules{headers: make(map[string]bool), fields: make(map[string]bool)}
+	if configBool("go.mask.disable") {
+		return r
+	}
+	for _, h := range append(defaultHeaders, configList("go.mask.headers")...) {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	names := make([]string, 0)
+	for _, f := range append(defaultFields, configList("go.mask.fields")...) {
+		r.fields[strings.ToLower(f)] = true
+		names = append(names, regexp.QuoteMeta(f))
+	}
+	for h := range r.headers {
+		names = append(names, regexp.QuoteMeta(h))
+	}
+	r.fieldRegexp = regexp.MustCompile(`(?i)"(` + strings.Join(names, "|") + `)"\s*:\s*("(?:[^"\\]|\\.)*"|[-0-9.eE]+|true|false)`)
+	r.paths = configList("go.mask.paths")
+	patterns := configList("go.mask.patterns")
+	if len(patterns) == 0 {
+		patterns = defaultPatterns
+	}
+	for _, p := range patterns {
+		switch p {
+		case "mobile":
+			r.mobile = true
+		case "idcard":
+			r.idCard = true
+		default:
+			re, err := regexp.Compile(p)
+			if err != nil {
+				logger.Error("脱敏正则" + p + "错误:" + err.Error())
+				continue
+			}
+			r.regexps = append(r.regexps, re)
+		}
+	}
+	return r
+}
+
+func configList(name string) []string {
+	cfg := config.Current()
+	if cfg.Cnf == nil {
+		return nil
+	}
+	if l := cfg.Cnf.Strings(name); len(l) > 0 {
+		return l
+	}
+	list := make([]string, 0)
+	for _, s := range strings.Split(config.Config.GetConfigString(name), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+func configBool(name string) bool {
+	return config.Current().Cnf != nil && config.Config.GetConfigBool(name)
+}
+
+// Text 对文本脱敏，包括文本中json格式的敏感字段、手机号、身份证号与配置的正则
+func Text(s string) string {
+	return getRules().text(s)
+}
+
+// Headers 对请求头脱敏，返回脱敏后的副本
+func Headers(headers map[string]string) map[string]string {
+	r := getRules()
+	masked := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if r.headers[http.CanonicalHeaderKey(k)] {
+			masked[k] = Replacement
+		} else {
+			masked[k] = r.text(v)
+		}
+	}
+	return masked
+}
+
+// Value 对请求参数、返回结果等数据脱敏，返回脱敏后的副本，结构体按json转换为map后脱敏，数字转换为json.Number
+// []byte按json字符串脱敏，不是json时按文本脱敏
+func Value(v interface{}) interface{} {
+	r := getRules()
+	switch b := v.(type) {
+	case nil, map[string]interface{}, []interface{}, map[string]string, string:
+		return r.value(v, "")
+	case []byte:
+		return JSON(string(b))
+	case json.RawMessage:
+		return JSON(string(b))
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var m interface{}
+	if utils.UnmarshalNumber(data, &m) != nil {
+		return v
+	}
+	return r.value(m, "")
+}
+
+// JSON 对json字符串脱敏，不是json时按文本脱敏
+func JSON(s string) string {
+	var v interface{}
+	if utils.UnmarshalNumber([]byte(s), &v) != nil {
+		return Text(s)
+	}
+	data, err := json.Marshal(getRules().value(v, ""))
+	if err != nil {
+		return Text(s)
+	}
+	return string(data)
+}
+
+// Field 字段名为敏感字段时脱敏整个值，否则对值脱敏
+func Field(name string, v interface{}) interface{} {
+	if getRules().fields[strings.ToLower(name)] {
+		return Replacement
+	}
+	return Value(v)
+}
+
+func (r *rules) value(v interface{}, path string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(t))
+		for k, x := range t {
+			p := joinPath(path, k)
+			if r.sensitive(k, p) {
+				masked[k] = Replacement
+			} else {
+				masked[k] = r.value(x, p)
+			}
+		}
+		return masked
+	case map[string]string:
+		masked := make(map[string]string, len(t))
+		for k, x := range t {
+			if r.sensitive(k, joinPath(path, k)) {
+				masked[k] = Replacement
+			} else {
+				masked[k] = r.text(x)
+			}
+		}
+		return masked
+	case []interface{}:
+		//数组元素沿用数组的路径
+		masked := make([]interface{}, len(t))
+		for i, x := range t {
+			masked[i] = r.value(x, path)
+		}
+		return masked
+	case string:
+		return r.text(t)
+	}
+	return v
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sensitive 字段名为敏感字段或路径与配置的JSON路径相同，路径中的*匹配任意字段
+func (r *rules) sensitive(key, path string) bool {
+	if r.fields[strings.ToLower(key)] {
+		return true
+	}
+	for _, p := range r.paths {
+		if pathMatch(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func pathMatch(pattern, path string) bool {
+	ps := strings.Split(pattern, ".")
+	keys := strings.Split(path, ".")
+	if len(ps) != len(keys) {
+		return false
+	}
+	for i := range ps {
+		if ps[i] != "*" && !strings.EqualFold(ps[i], keys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *rules) text(s string) string {
+	if s == "" {
+		return s
+	}
+	if r.fieldRegexp != nil && strings.Contains(s, `"`) {
+		s = r.fieldRegexp.ReplaceAllString(s, `"$1":"`+Replacement+`"`)
+	}
+	if r.mobile || r.idCard {
+		s = r.digits(s)
+	}
+	for _, re := range r.regexps {
+		s = re.ReplaceAllString(s, Replacement)
+	}
+	return s
+}
+
+// digits 手机号保留前3位与后4位，身份证号保留前4位与后4位
+func (r *rules) digits(s string) string {
+	indexes := digitsRegexp.FindAllStringIndex(s, -1)
+	if indexes == nil {
+		return s
+	}
+	var b strings.Builder
+	last := 0
+	for _, idx := range indexes {
+		start, end := idx[0], idx[1]
+		if (start > 0 && isAlnum(s[start-1])) || (end < len(s) && isAlnum(s[end])) {
+			continue
+		}
+		d := s[start:end]
+		switch {
+		case r.idCard && utils.IsIdCard(d) && (len(d) == 15 || idCardChecksum(d)):
+			d = d[:4] + strings.Repeat("*", len(d)-8) + d[len(d)-4:]
+		case r.mobile && utils.IsChinaMobileString(d):
+			d = d[:3] + "****" + d[7:]
+		default:
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(d)
+		last = end
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// idCardChecksum 18位身份证号的校验码，避免将18位的数字id误判为身份证号
+func idCardChecksum(id string) bool {
+	if len(id) != 18 {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(id[i]-'0') * w
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(id[17:])[0]
+}
+
+// logMasker 注册到logs，对日志内容与With附加的字段脱敏
+type logMasker struct{}
+
+func (logMasker) Text(s string) string {
+	return Text(s)
+}
+
+func (logMasker) Field(name string, v interface{}) interface{} {
+	return Field(name, v)
+}
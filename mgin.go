@@ -4,7 +4,10 @@ import (
 	"github.com/maczh/mgin/config"
 	"github.com/maczh/mgin/db"
 	"github.com/maczh/mgin/logs"
+	_ "github.com/maczh/mgin/mask" //日志脱敏
+	"github.com/maczh/mgin/middleware/postlog"
 	"github.com/maczh/mgin/registry"
+	"github.com/maczh/mgin/tracing"
 	"github.com/sadlil/gologger"
 	"strings"
 	"time"
@@ -61,6 +64,7 @@ func (m *mgin) Use(dbConfigName string, dbInit dbInitFunc, dbClose dbCloseFunc,
 }
 func Init(configFile string) {
 	config.Config.Init(configFile)
+	tracing.Init()
 	configs := config.Config.Config.Used
 
 	if strings.Contains(configs, "mysql") {
@@ -158,6 +162,9 @@ func (m *mgin) checkAll() {
 
 func (m *mgin) SafeExit() {
 	configs := config.Config.Config.Used
+	//关闭数据库、kafka与es连接前先写入待写入的日志
+	postlog.Flush()
+	logs.Flush()
 
 	if strings.Contains(configs, "mysql") {
 		logger.Info("正在关闭MySQL连接")
@@ -191,5 +198,6 @@ func (m *mgin) SafeExit() {
 			}
 		}
 	}
-
+	tracing.Shutdown()
+	logs.Flush()
 }
@@ -0,0 +1,49 @@
+package mtls
+
+import (
+	"crypto/x509"
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/errcode"
+	"github.com/maczh/mgin/i18n"
+	"net/http"
+)
+
+// CallerServiceKey 调用方服务名在gin上下文中的键
+const CallerServiceKey = "mgin.callerService"
+
+// CallerService 从双向认证的客户端证书中取出调用方服务名存入gin上下文
+// 指定allowServices时，仅允许列表中的服务调用
+func CallerService(allowServices ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool)
+	for _, s := range allowServices {
+		allowed[s] = true
+	}
+	return func(c *gin.Context) {
+		service := ""
+		if c.Request.TLS != nil && len(c.Request.TLS.PeerCertificates) > 0 {
+			service = serviceName(c.Request.TLS.PeerCertificates[0])
+			c.Set(CallerServiceKey, service)
+		}
+		if len(allowed) > 0 && !allowed[service] {
+			c.AbortWithStatusJSON(http.StatusOK, i18n.ErrorFrom(c, errcode.AUTHENTICATION_FAILURE, errcode.CallerUnauthorized))
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetCallerService 获取客户端证书中的调用方服务名，非双向认证请求返回空
+func GetCallerService(c *gin.Context) string {
+	return c.GetString(CallerServiceKey)
+}
+
+// serviceName 证书中的服务名，优先取CN，其次取第一个DNS名称
+func serviceName(cert *x509.Certificate) string {
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package postlog
+
+import (
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+// ApiNameKey 接口名称在gin上下文中的键
+const ApiNameKey = "mgin.apiName"
+
+// ApiName 在注册路由时设置接口名称，记录在接口访问日志的apiName中
+//
+//	engine.POST("/user/add", postlog.ApiName("添加用户"), controller.AddUser)
+func ApiName(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ApiNameKey, name)
+		c.Next()
+	}
+}
+
+// SetApiName 在接口处理中设置接口名称
+func SetApiName(c *gin.Context, name string) {
+	c.Set(ApiNameKey, name)
+}
+
+// GetApiName 获取接口名称，未设置时返回空
+func GetApiName(c *gin.Context) string {
+	return c.GetString(ApiNameKey)
+}
+
+// queryParams 请求地址中的参数，同名的多个参数以逗号连接
+func queryParams(c *gin.Context) map[string]string {
+	values := c.Request.URL.Query()
+	if len(values) == 0 {
+		return nil
+	}
+	query := make(map[string]string, len(values))
+	for k, v := range values {
+		query[k] = strings.Join(v, ",")
+	}
+	return query
+}
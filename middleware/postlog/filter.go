@@ -0,0 +1,98 @@
+package postlog
+
+import (
+	"encoding/json"
+	"github.com/maczh/mgin/config"
+	"math/rand"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 未配置go.log.exclude时不记录的路径
+var defaultExclude = []string{"/", "/docs", "/docs/**"}
+
+// 返回内容被截断时从记录的部分中取status，models.Result中status为第一个字段
+var statusRegexp = regexp.MustCompile(`"status"\s*:\s*(-?\d+)`)
+
+// pathMatch 路径匹配，*匹配一级路径中的任意字符，以/**结尾时匹配该路径及其所有子路径
+func pathMatch(pattern, p string) bool {
+	if strings.HasSuffix(pattern, "/**") {
+		prefix := strings.TrimSuffix(pattern, "/**")
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	ok, _ := path.Match(pattern, p)
+	return ok
+}
+
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if pathMatch(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// logPath 配置了go.log.include时只记录匹配的路径，匹配go.log.exclude的路径不记录
+func logPath(p string) bool {
+	cfg := config.Current()
+	if len(cfg.Log.Include) > 0 && !matchAny(cfg.Log.Include, p) {
+		return false
+	}
+	exclude := cfg.Log.Exclude
+	if len(exclude) == 0 {
+		exclude = defaultExclude
+	}
+	return !matchAny(exclude, p)
+}
+
+// sampled 按路径的采样率决定是否记录，go.log.samples中有多个匹配时取最长的路径
+func sampled(p string) bool {
+	cfg := config.Current()
+	if cfg.Cnf == nil {
+		return true
+	}
+	rate := cfg.Log.Sample
+	matched := ""
+	for pattern, r := range cfg.Log.Samples {
+		if len(pattern) > len(matched) && pathMatch(pattern, p) {
+			matched, rate = pattern, r
+		}
+	}
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+// failed HTTP状态码不小于400，或返回结果的status不为1
+func failed(httpStatus int, result map[string]interface{}, responseBody string) bool {
+	if httpStatus >= 400 {
+		return true
+	}
+	if result != nil {
+		if status, ok := result["status"]; ok {
+			n, isNumber := status.(json.Number)
+			return !isNumber || n.String() != "1"
+		}
+		return false
+	}
+	if m := statusRegexp.FindStringSubmatch(responseBody); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		return status != 1
+	}
+	return false
+}
+
+// truncate 按最大字节数截断，不截断utf8字符
+func truncate(s string, max int) (string, bool) {
+	if max <= 0 || len(s) <= max {
+		return s, false
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max], true
+}
@@ -2,177 +2,242 @@ package postlog
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"github.com/maczh/mgin/config"
-	"github.com/maczh/mgin/db"
 	"github.com/maczh/mgin/models"
+	"io"
 	"io/ioutil"
+	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/mask"
 	"github.com/maczh/mgin/middleware/trace"
 	"github.com/maczh/mgin/utils"
 	"gopkg.in/mgo.v2/bson"
 )
 
+// bodyLogWriter 记录返回内容，超过limit的部分不记录
 type bodyLogWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	body  *bytes.Buffer
+	limit int
 }
 
-var accessChannel = make(chan string, 100)
-
-func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	w.capture(b)
 	return w.ResponseWriter.Write(b)
 }
 
-func (w bodyLogWriter) WriteString(s string) (int, error) {
-	w.body.WriteString(s)
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
 	return w.ResponseWriter.WriteString(s)
 }
 
+// capture 多记录一个字节用于判断返回内容是否被截断
+func (w *bodyLogWriter) capture(b []byte) {
+	if w.limit <= 0 {
+		w.body.Write(b)
+		return
+	}
+	if remain := w.limit + 1 - w.body.Len(); remain > 0 {
+		if len(b) > remain {
+			b = b[:remain]
+		}
+		w.body.Write(b)
+	}
+}
+
 func RequestLogger() gin.HandlerFunc {
 
-	go handleAccessChannel()
+	start()
 
 	return func(c *gin.Context) {
-		bodyLogWriter := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		if !logPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		bodyLogWriter := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer, limit: config.Current().Log.MaxResponse}
 		c.Writer = bodyLogWriter
 
 		// 开始时间
 		startTime := time.Now()
 
-		data, err := c.GetRawData()
-		if err != nil {
-			logs.Error("GetRawData error:", err.Error())
+		// 上传文件的请求只记录表单参数，不读取请求体
+		body, bodyTruncated := "", false
+		if !strings.Contains(c.ContentType(), "multipart/form-data") {
+			body, bodyTruncated = peekBody(c, config.Current().Log.MaxRequest)
 		}
-		body := string(data)
 
-		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(data)) // 关键点
+		// 未被内层恢复的panic记录后继续抛出
+		defer func() {
+			if err := recover(); err != nil {
+				SetPanic(c, err)
+				writeLog(c, bodyLogWriter, startTime, body, bodyTruncated)
+				panic(err)
+			}
+		}()
+
 		// 处理请求
 		c.Next()
 
-		responseBody := bodyLogWriter.body.String()
+		writeLog(c, bodyLogWriter, startTime, body, bodyTruncated)
+	}
+}
 
-		var req map[string]interface{}
-		var result map[string]interface{}
+// peekBody 读取请求体的前limit+1个字节用于记录日志，已读取的内容与未读取的部分依次交给后续处理，limit不大于0时读取全部
+func peekBody(c *gin.Context, limit int) (string, bool) {
+	raw := c.Request.Body
+	if raw == nil || raw == http.NoBody {
+		return "", false
+	}
+	var r io.Reader = raw
+	if limit > 0 {
+		r = io.LimitReader(raw, int64(limit)+1)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		logs.WithContext(c).Error("读取请求体错误:{}", err.Error())
+	}
+	c.Request.Body = &peekedBody{Reader: io.MultiReader(bytes.NewReader(data), raw), Closer: raw}
+	return truncate(string(data), limit)
+}
 
-		// 日志格式
-		if strings.Contains(c.Request.RequestURI, "/docs") || c.Request.RequestURI == "/" {
-			return
-		}
+// peekedBody 已读取部分内容的请求体
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
 
-		if responseBody != "" && responseBody[0:1] == "{" {
-			err := json.Unmarshal([]byte(responseBody), &result)
-			if err != nil {
-				result = map[string]interface{}{"status": -1, "msg": "解析异常:" + err.Error()}
-			}
-		}
+func writeLog(c *gin.Context, bodyLogWriter *bodyLogWriter, startTime time.Time, body string, bodyTruncated bool) {
+	cfg := config.Current()
+	responseBody, responseTruncated := truncate(bodyLogWriter.body.String(), cfg.Log.MaxResponse)
 
-		// 结束时间
-		endTime := time.Now()
-
-		// 日志格式
-		var params interface{}
-		if strings.Contains(c.ContentType(), "application/json") && body != "" {
-			utils.FromJSON(body, &req)
-			params = req
-		} else if strings.Contains(c.ContentType(), "x-www-form-urlencoded") || strings.Contains(c.ContentType(), "multipart/form-data") {
-			params = utils.GinParamMap(c)
-		} else if c.Request.Method != "GET" && c.Request.Method != "DELETE" {
-			return
+	var req map[string]interface{}
+	var result map[string]interface{}
+
+	if !responseTruncated && responseBody != "" && responseBody[0:1] == "{" {
+		err := utils.UnmarshalNumber([]byte(responseBody), &result)
+		if err != nil {
+			result = map[string]interface{}{"status": -1, "msg": "解析异常:" + err.Error()}
 		}
-		postLog := new(models.PostLog)
-		postLog.ID = bson.NewObjectId()
-		postLog.Time = startTime.Format("2006-01-02 15:04:05")
-		postLog.Uri = c.Request.RequestURI
-		postLog.Method = c.Request.Method
-		postLog.AppName = config.Config.App.Name
-		postLog.RequestId = trace.GetRequestId()
-		postLog.ContentType = c.ContentType()
-		postLog.RequestHeader = utils.GinHeaders(c)
-		ip := c.GetHeader("X-Forward-For")
-		if ip == "" {
-			ip = c.GetHeader("X-Real-IP")
-			if ip == "" {
-				ip = c.ClientIP()
+	}
+
+	// panic未写入返回时按500记录
+	p := getPanic(c)
+	statusCode := c.Writer.Status()
+	if p != nil && !c.Writer.Written() {
+		statusCode = http.StatusInternalServerError
+	}
+
+	// 结束时间
+	endTime := time.Now()
+	ttl := int(endTime.UnixNano()/1e6 - startTime.UnixNano()/1e6)
+
+	// 出错与慢请求总是记录，其他请求按采样率记录
+	slow := cfg.Log.Slow > 0 && ttl >= cfg.Log.Slow
+	if slow {
+		logs.WithContext(c).Warn("慢请求:{} {} {}ms", c.Request.Method, c.Request.RequestURI, ttl)
+	}
+	isError := p != nil || len(c.Errors) > 0 || failed(statusCode, result, responseBody)
+	if !slow && !(cfg.Log.OnError && isError) && !sampled(c.Request.URL.Path) {
+		return
+	}
+
+	// 日志格式
+	var params interface{}
+	if strings.Contains(c.ContentType(), "application/json") && body != "" {
+		if bodyTruncated {
+			params = body
+		} else {
+			if utils.UnmarshalNumber([]byte(body), &req) == nil {
+				params = req
+			} else {
+				params = body
 			}
 		}
-		postLog.ClientIP = ip
-		postLog.RequestParam = params
-		postLog.ResponseTime = endTime.Format("2006-01-02 15:04:05")
-		postLog.ResponseMap = result
-		postLog.TTL = int(endTime.UnixNano()/1e6 - startTime.UnixNano()/1e6)
-
-		accessLog := "|" + c.Request.Method + "|" + postLog.Uri + "|" + c.ClientIP() + "|" + endTime.Format("2006-01-02 15:04:05.012") + "|" + fmt.Sprintf("%vms", endTime.UnixNano()/1e6-startTime.UnixNano()/1e6)
-		logs.Debug(accessLog)
-		logs.Debug("请求参数:{}", params)
-		logs.Debug("请求头:{}", postLog.RequestHeader)
-		logs.Debug("接口返回:{}", result)
-
-		if config.Config.Log.RequestTableName != "" || config.Config.Log.Kafka.Use {
-			accessChannel <- utils.ToJSON(postLog)
+	} else if strings.Contains(c.ContentType(), "x-www-form-urlencoded") || strings.Contains(c.ContentType(), "multipart/form-data") {
+		params = utils.GinParamMap(c)
+	} else if body != "" && textual(c.ContentType(), body) {
+		params = body
+	}
+	postLog := new(models.PostLog)
+	postLog.ID = bson.NewObjectId()
+	postLog.Time = startTime.Format("2006-01-02 15:04:05")
+	postLog.Uri = c.Request.URL.Path
+	postLog.Route = c.FullPath()
+	postLog.Apiname = GetApiName(c)
+	if query := queryParams(c); query != nil {
+		postLog.Query = mask.Value(query).(map[string]string)
+	}
+	postLog.Method = c.Request.Method
+	postLog.AppName = cfg.App.Name
+	postLog.RequestId = trace.GetRequestIdFrom(c)
+	postLog.ContentType = c.ContentType()
+	postLog.RequestHeader = mask.Headers(utils.GinHeaders(c))
+	ip := c.GetHeader("X-Forward-For")
+	if ip == "" {
+		ip = c.GetHeader("X-Real-IP")
+		if ip == "" {
+			ip = c.ClientIP()
 		}
 	}
+	postLog.ClientIP = ip
+	postLog.RequestParam = mask.Value(params)
+	postLog.ResponseTime = endTime.Format("2006-01-02 15:04:05")
+	postLog.StatusCode = statusCode
+	if size := c.Writer.Size(); size > 0 {
+		postLog.ResponseSize = size
+	}
+	if result != nil {
+		postLog.ResponseMap = mask.Value(result).(map[string]interface{})
+	} else if responseBody != "" && textual(c.Writer.Header().Get("Content-Type"), responseBody) {
+		//数组、xml与文本等非json对象的返回，非文本内容如文件下载只记录大小
+		postLog.ResponseStr = mask.JSON(responseBody)
+	}
+	if p != nil {
+		postLog.Panic = mask.Text(p.value)
+		postLog.Stack = p.stack
+	}
+	for _, e := range c.Errors {
+		postLog.Errors = append(postLog.Errors, mask.Text(e.Error()))
+	}
+	postLog.TTL = ttl
+
+	accessLog := "|" + c.Request.Method + "|" + c.Request.RequestURI + "|" + c.ClientIP() + "|" + endTime.Format("2006-01-02 15:04:05.012") + "|" + fmt.Sprintf("%vms", ttl) + "|" + fmt.Sprint(statusCode)
+	logs.WithContext(c).Debug(accessLog)
+	logs.WithContext(c).Debug("请求参数:{}", postLog.RequestParam)
+	logs.WithContext(c).Debug("请求头:{}", postLog.RequestHeader)
+	if postLog.ResponseMap != nil {
+		logs.WithContext(c).Debug("接口返回:{}", postLog.ResponseMap)
+	} else {
+		logs.WithContext(c).Debug("接口返回:{}", postLog.ResponseStr)
+	}
+	if postLog.Panic != "" {
+		logs.WithContext(c).Debug("接口异常:{}", postLog.Panic)
+	}
+	if len(postLog.Errors) > 0 {
+		logs.WithContext(c).Debug("接口错误:{}", postLog.Errors)
+	}
+
+	if cfg.Log.RequestTableName != "" || cfg.Log.Kafka.Use {
+		enqueue(postLog)
+	}
 }
 
-func handleAccessChannel() {
-	if config.Config.Log.LogDb == "" {
-		config.Config.Log.LogDb = "mongodb"
+// textual 按Content-Type判断是否为文本内容，未设置时按内容是否为有效的utf8判断
+func textual(contentType, content string) bool {
+	contentType = strings.ToLower(contentType)
+	if contentType == "" {
+		return utf8.ValidString(content)
 	}
-	for accessLog := range accessChannel {
-		var postLog models.PostLog
-		json.Unmarshal([]byte(accessLog), &postLog)
-		dbName := ""
-		if config.Config.Log.DbName != "" {
-			dbName = postLog.RequestHeader[config.Config.Log.DbName]
-		}
-		//是否写入到kafka
-		if config.Config.Log.Kafka.Use {
-			topics := strings.Split(config.Config.Log.Kafka.Topic, ",")
-			for _, topic := range topics {
-				if dbName != "" {
-					topic = fmt.Sprintf("%s_%s", topic, dbName)
-				}
-				err := db.Kafka.Send(topic, accessLog)
-				if err != nil {
-					logs.Error("接口日志发送到kafka的{}主题失败:{}", topic, err.Error())
-				}
-			}
-		}
-		if dbName == "" && db.Mongo.IsMultiDB() {
-			logs.Error("日志多库header配置{}错误，请求头中无此参数值", config.Config.Log.DbName)
-			continue
-		}
-		if config.Config.Log.RequestTableName == "" {
-			continue
-		}
-		switch config.Config.Log.LogDb {
-		case "mongodb":
-			conn, err := db.Mongo.GetConnection(dbName)
-			if err != nil {
-				logs.Error("MongoDB连接失败:{}", err.Error())
-				continue
-			}
-			err = conn.C(config.Config.Log.RequestTableName).Insert(postLog)
-			if err != nil {
-				logs.Error("MongoDB写入错误:" + err.Error())
-			}
-			db.Mongo.ReturnConnection(conn)
-		case "elasticsearch":
-			doc := make(map[string]interface{})
-			utils.FromJSON(utils.ToJSON(postLog), &doc)
-			resp, err := db.ElasticSearch.AddDocument(strings.ToLower(config.Config.App.Project), strings.ToLower(config.Config.Log.RequestTableName), doc, []string{})
-			if err != nil {
-				logs.Error("ElasticSearch写入日志失败:{}", err.Error())
-				continue
-			}
-			logs.Debug("日志写入ElasticSearch返回:{}", resp)
+	for _, t := range []string{"text/", "json", "xml", "javascript", "x-www-form-urlencoded"} {
+		if strings.Contains(contentType, t) {
+			return true
 		}
 	}
-	return
+	return false
 }
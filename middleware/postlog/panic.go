@@ -0,0 +1,42 @@
+package postlog
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"runtime/debug"
+)
+
+// PanicKey 接口处理中恢复的panic在gin上下文中的键
+const PanicKey = "mgin.panic"
+
+type recovered struct {
+	value string
+	stack string
+}
+
+// SetPanic 记录恢复的panic与调用栈，需在recover所在的defer中调用，调用栈才包含panic的位置
+func SetPanic(c *gin.Context, err interface{}) {
+	if _, ok := c.Get(PanicKey); ok {
+		return
+	}
+	c.Set(PanicKey, &recovered{value: fmt.Sprint(err), stack: string(debug.Stack())})
+}
+
+// RecoveryHandler 包装全局异常处理，先记录panic再调用f，panic与调用栈记录在接口访问日志中
+//
+//	engine.Use(nice.Recovery(postlog.RecoveryHandler(recoveryHandler)))
+func RecoveryHandler(f func(c *gin.Context, err interface{})) func(c *gin.Context, err interface{}) {
+	return func(c *gin.Context, err interface{}) {
+		SetPanic(c, err)
+		if f != nil {
+			f(c, err)
+		}
+	}
+}
+
+func getPanic(c *gin.Context) *recovered {
+	if v, ok := c.Get(PanicKey); ok {
+		return v.(*recovered)
+	}
+	return nil
+}
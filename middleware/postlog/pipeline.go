@@ -0,0 +1,192 @@
+package postlog
+
+import (
+	"encoding/json"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/models"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBuffer   = 10000
+	defaultBatch    = 100
+	defaultInterval = time.Second
+	flushTimeout    = 5 * time.Second
+	// 队列已满时写入本地文件
+	OverflowSpill = "spill"
+	// 队列已满时丢弃并计数
+	OverflowDrop = "drop"
+)
+
+// Metrics 接口访问日志的写入统计
+type Metrics struct {
+	Queued  uint64 `json:"queued"`  //进入队列的日志数
+	Written uint64 `json:"written"` //写入各存储成功的日志数，多个存储时分别计数
+	Failed  uint64 `json:"failed"`  //写入各存储失败的日志数，失败的日志写入本地文件
+	Dropped uint64 `json:"dropped"` //队列已满时丢弃的日志数
+	Spilled uint64 `json:"spilled"` //写入本地文件的日志数
+}
+
+var (
+	records   chan *models.PostLog
+	flushes   = make(chan chan struct{})
+	startOnce sync.Once
+	started   int32
+	metrics   Metrics
+	spillLock sync.Mutex
+)
+
+// start 队列大小修改后需重启生效
+func start() {
+	startOnce.Do(func() {
+		size := config.Current().Log.Buffer
+		if size <= 0 {
+			size = defaultBuffer
+		}
+		records = make(chan *models.PostLog, size)
+		go loop()
+		atomic.StoreInt32(&started, 1)
+	})
+}
+
+// enqueue 不阻塞请求，队列已满时按go.log.overflow丢弃或写入本地文件
+func enqueue(record *models.PostLog) {
+	select {
+	case records <- record:
+		atomic.AddUint64(&metrics.Queued, 1)
+	default:
+		if config.Current().Log.Overflow == OverflowDrop {
+			if atomic.AddUint64(&metrics.Dropped, 1)%1000 == 1 {
+				logs.Warn("接口访问日志队列已满，已丢弃{}条", atomic.LoadUint64(&metrics.Dropped))
+			}
+			return
+		}
+		spill("queue", []*models.PostLog{record})
+	}
+}
+
+// loop 按数量与时间间隔批量写入，批量大小与间隔每次按当前配置获取
+func loop() {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	var batch []*models.PostLog
+	lastWrite := time.Now()
+	write := func() {
+		if len(batch) > 0 {
+			writeBatch(batch)
+			batch = nil
+		}
+		lastWrite = time.Now()
+	}
+	for {
+		select {
+		case record := <-records:
+			batch = append(batch, record)
+			if len(batch) >= batchSize() {
+				write()
+			}
+		case <-ticker.C:
+			if time.Since(lastWrite) >= batchInterval() {
+				write()
+			}
+		case done := <-flushes:
+			for len(records) > 0 {
+				batch = append(batch, <-records)
+			}
+			write()
+			close(done)
+		}
+	}
+}
+
+func batchSize() int {
+	if config.Current().Log.Batch > 0 {
+		return config.Current().Log.Batch
+	}
+	return defaultBatch
+}
+
+func batchInterval() time.Duration {
+	if config.Current().Log.Interval > 0 {
+		return time.Duration(config.Current().Log.Interval) * time.Second
+	}
+	return defaultInterval
+}
+
+// writeBatch 写入各存储，失败时该批日志按存储名称写入本地文件
+func writeBatch(batch []*models.PostLog) {
+	for _, name := range activeSinks() {
+		sink, ok := sinks.Load(name)
+		if !ok {
+			logs.Error("接口访问日志存储{}未注册", name)
+			continue
+		}
+		if err := sink.(Sink).Write(batch); err != nil {
+			atomic.AddUint64(&metrics.Failed, uint64(len(batch)))
+			logs.Error("接口访问日志写入{}失败:{}", name, err.Error())
+			spill(name, batch)
+			continue
+		}
+		atomic.AddUint64(&metrics.Written, uint64(len(batch)))
+	}
+}
+
+// spill 写入本地文件{file}-access-{name}-spill.yyyy-MM-dd.log，每行一个json，可在存储恢复后重新导入
+func spill(name string, batch []*models.PostLog) {
+	location := config.Current().Logger.File
+	if location == "" {
+		location = filepath.Join("logs", config.Current().App.Name)
+	}
+	fileName := location + "-access-" + name + "-spill." + time.Now().Format("2006-01-02") + ".log"
+	spillLock.Lock()
+	defer spillLock.Unlock()
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		logs.Error("接口访问日志写入本地文件失败:{}", err.Error())
+		return
+	}
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		logs.Error("接口访问日志写入本地文件失败:{}", err.Error())
+		return
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, record := range batch {
+		if err := encoder.Encode(record); err != nil {
+			logs.Error("接口访问日志写入本地文件失败:{}", err.Error())
+			return
+		}
+		atomic.AddUint64(&metrics.Spilled, 1)
+	}
+}
+
+// Stats 接口访问日志的写入统计
+func Stats() Metrics {
+	return Metrics{
+		Queued:  atomic.LoadUint64(&metrics.Queued),
+		Written: atomic.LoadUint64(&metrics.Written),
+		Failed:  atomic.LoadUint64(&metrics.Failed),
+		Dropped: atomic.LoadUint64(&metrics.Dropped),
+		Spilled: atomic.LoadUint64(&metrics.Spilled),
+	}
+}
+
+// Flush 写入队列中的接口访问日志，退出前调用
+func Flush() {
+	if atomic.LoadInt32(&started) == 0 {
+		return
+	}
+	done := make(chan struct{})
+	select {
+	case flushes <- done:
+		select {
+		case <-done:
+		case <-time.After(flushTimeout):
+		}
+	case <-time.After(flushTimeout):
+	}
+}
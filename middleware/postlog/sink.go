@@ -0,0 +1,205 @@
+package postlog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/db"
+	"github.com/maczh/mgin/models"
+	"github.com/maczh/mgin/utils"
+	"github.com/olivere/elastic"
+	"strings"
+	"sync"
+)
+
+// Sink 接口访问日志的存储，每批日志调用一次Write，返回错误时该批日志写入本地文件
+// 内置mongodb、elasticsearch与kafka，其他存储如mysql、文件可通过RegisterSink注册后在go.log.db中配置
+type Sink interface {
+	Write(records []*models.PostLog) error
+}
+
+// SinkFunc 以函数实现Sink
+type SinkFunc func(records []*models.PostLog) error
+
+func (f SinkFunc) Write(records []*models.PostLog) error {
+	return f(records)
+}
+
+var sinks sync.Map
+
+// RegisterSink 注册接口访问日志的存储，同名时替换
+func RegisterSink(name string, sink Sink) {
+	sinks.Store(name, sink)
+}
+
+func init() {
+	RegisterSink("mongodb", SinkFunc(mongoSink))
+	RegisterSink("elasticsearch", &esSink{})
+	RegisterSink("kafka", SinkFunc(kafkaSink))
+}
+
+// activeSinks go.log.db中配置的存储，支持逗号分隔多个，未配置表名时不写入，kafka由go.log.kafka.use决定
+func activeSinks() []string {
+	cfg := config.Current()
+	names := make([]string, 0)
+	if cfg.Log.RequestTableName != "" {
+		logDb := cfg.Log.LogDb
+		if logDb == "" {
+			logDb = "mongodb"
+		}
+		for _, name := range strings.Split(logDb, ",") {
+			if name = strings.TrimSpace(name); name != "" && name != "kafka" {
+				names = append(names, name)
+			}
+		}
+	}
+	if cfg.Log.Kafka.Use {
+		names = append(names, "kafka")
+	}
+	return names
+}
+
+// dbName 多库时从请求头中取日志库名称
+func dbName(record *models.PostLog) string {
+	cfg := config.Current()
+	if cfg.Log.DbName == "" {
+		return ""
+	}
+	return record.RequestHeader[cfg.Log.DbName]
+}
+
+// groupByDb 按日志库名称分组
+func groupByDb(records []*models.PostLog) map[string][]*models.PostLog {
+	groups := make(map[string][]*models.PostLog)
+	for _, record := range records {
+		name := dbName(record)
+		groups[name] = append(groups[name], record)
+	}
+	return groups
+}
+
+func mongoSink(records []*models.PostLog) error {
+	var errs []string
+	for name, group := range groupByDb(records) {
+		if name == "" && db.Mongo.IsMultiDB() {
+			errs = append(errs, fmt.Sprintf("日志多库header配置%s错误，请求头中无此参数值", config.Current().Log.DbName))
+			continue
+		}
+		conn, err := db.Mongo.GetConnection(name)
+		if err != nil {
+			errs = append(errs, "MongoDB连接失败:"+err.Error())
+			continue
+		}
+		docs := make([]interface{}, len(group))
+		for i, record := range group {
+			docs[i] = record
+		}
+		err = conn.C(config.Current().Log.RequestTableName).Insert(docs...)
+		db.Mongo.ReturnConnection(conn)
+		if err != nil {
+			errs = append(errs, "MongoDB写入错误:"+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ";"))
+	}
+	return nil
+}
+
+// esSink 写入project_table索引，每个索引首次写入时检查，不存在时按IK与拼音分词创建，文档以uuid作为_id与id字段
+type esSink struct {
+	indices sync.Map
+}
+
+// esDoc 写入ElasticSearch的文档，在访问日志的字段上加上id
+type esDoc struct {
+	Id string `json:"id"`
+	*models.PostLog
+}
+
+func (s *esSink) Write(records []*models.PostLog) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if db.ElasticSearch.Elastic == nil {
+		return errors.New("ElasticSearch未连接")
+	}
+	cfg := config.Current()
+	indexName := fmt.Sprintf("%s_%s", strings.ToLower(cfg.App.Project), strings.ToLower(cfg.Log.RequestTableName))
+	bulk := db.ElasticSearch.Elastic.Bulk()
+	for i, record := range records {
+		uid, _ := uuid.NewV4()
+		data, err := json.Marshal(esDoc{Id: uid.String(), PostLog: record})
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			if err = s.createIndex(indexName, data); err != nil {
+				return err
+			}
+		}
+		bulk.Add(elastic.NewBulkIndexRequest().Index(indexName).Type("_doc").Id(uid.String()).Doc(json.RawMessage(data)))
+	}
+	resp, err := bulk.Do(context.Background())
+	if err != nil {
+		return err
+	}
+	if resp.Errors {
+		return errors.New("ElasticSearch批量写入存在错误")
+	}
+	return nil
+}
+
+// createIndex 索引不存在时以第一条日志生成字段映射创建，每个索引只检查一次
+func (s *esSink) createIndex(indexName string, sample []byte) error {
+	if _, ok := s.indices.Load(indexName); ok {
+		return nil
+	}
+	var doc map[string]interface{}
+	if err := utils.UnmarshalNumber(sample, &doc); err != nil {
+		return err
+	}
+	if err := db.ElasticSearch.CreateIndexIfNotExists(indexName, doc, []string{}); err != nil {
+		return err
+	}
+	s.indices.Store(indexName, true)
+	return nil
+}
+
+// kafkaSink 发送到go.log.kafka.topic中的各主题，多库时主题名称加上_库名
+func kafkaSink(records []*models.PostLog) error {
+	var errs []string
+	for name, group := range groupByDb(records) {
+		docs, err := marshal(group)
+		if err != nil {
+			return err
+		}
+		for _, topic := range strings.Split(config.Current().Log.Kafka.Topic, ",") {
+			if name != "" {
+				topic = fmt.Sprintf("%s_%s", topic, name)
+			}
+			if err := db.Kafka.SendMsgsSync(topic, docs); err != nil {
+				errs = append(errs, fmt.Sprintf("发送到kafka的%s主题失败:%s", topic, err.Error()))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ";"))
+	}
+	return nil
+}
+
+func marshal(records []*models.PostLog) ([]string, error) {
+	docs := make([]string, len(records))
+	for i, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = string(data)
+	}
+	return docs, nil
+}
@@ -0,0 +1,130 @@
+package sign
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/cache"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/db"
+	"github.com/maczh/mgin/errcode"
+	"github.com/maczh/mgin/i18n"
+	"github.com/maczh/mgin/logs"
+	"github.com/maczh/mgin/utils"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var nonceLock sync.Mutex
+
+// Verify 校验微服务间的请求签名，签名密钥为go.sign.key，未配置密钥时不校验
+// 时间戳允许的误差为go.sign.skew秒，默认300秒，随机串在两倍误差时间内只能使用一次
+// go.sign.nonce为redis时随机串存放在redis中，否则存放在本地缓存
+func Verify() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := config.Config.GetConfigString("go.sign.key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		timestamp := c.GetHeader(utils.HeaderTimestamp)
+		nonce := c.GetHeader(utils.HeaderNonce)
+		signature := c.GetHeader(utils.HeaderSignature)
+		if timestamp == "" || nonce == "" || signature == "" {
+			abort(c, errcode.SignatureLost)
+			return
+		}
+		skew := config.Config.GetConfigInt("go.sign.skew")
+		if skew <= 0 {
+			skew = 300
+		}
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		diff := time.Now().Unix() - ts
+		if err != nil || diff > int64(skew) || diff < -int64(skew) {
+			abort(c, errcode.RequestExpired)
+			return
+		}
+		//流式上传的请求体不参与签名，只允许go.sign.unsigned中配置的接口以multipart上传
+		bodyHash := c.GetHeader(utils.HeaderContentSha256)
+		if bodyHash == utils.UnsignedPayload && !unsignedAllowed(c) {
+			logs.WithContext(c).Warn("来自{}的请求{}不允许请求体不签名", c.GetHeader(utils.HeaderCaller), c.Request.URL.Path)
+			abort(c, errcode.SignatureError)
+			return
+		}
+		if bodyHash != utils.UnsignedPayload {
+			data, err := c.GetRawData()
+			if err != nil {
+				logs.WithContext(c).Error("GetRawData error:{}", err.Error())
+			}
+			c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+			bodyHash = utils.Sha256(string(data))
+		}
+		expected := utils.RequestSign(key, c.Request.Method, c.Request.URL.Path, c.Request.URL.Query(), bodyHash, timestamp, nonce)
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			logs.WithContext(c).Warn("来自{}的请求签名错误", c.GetHeader(utils.HeaderCaller))
+			abort(c, errcode.SignatureError)
+			return
+		}
+		if !useNonce(nonce, 2*time.Duration(skew)*time.Second) {
+			logs.WithContext(c).Warn("来自{}的重复请求,nonce:{}", c.GetHeader(utils.HeaderCaller), nonce)
+			abort(c, errcode.RequestReplayed)
+			return
+		}
+		c.Next()
+	}
+}
+
+// unsignedAllowed multipart上传且路径匹配go.sign.unsigned，*匹配一级路径，以/**结尾时匹配所有子路径
+func unsignedAllowed(c *gin.Context) bool {
+	if c.ContentType() != "multipart/form-data" {
+		return false
+	}
+	p := c.Request.URL.Path
+	for _, pattern := range config.Config.GetConfigStrings("go.sign.unsigned") {
+		if prefix := strings.TrimSuffix(pattern, "/**"); prefix != pattern && (p == prefix || strings.HasPrefix(p, prefix+"/")) {
+			return true
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func abort(c *gin.Context, messageId string) {
+	c.AbortWithStatusJSON(http.StatusOK, i18n.ErrorFrom(c, errcode.AUTHENTICATION_FAILURE, messageId))
+}
+
+// useNonce 登记随机串，已使用过的返回false
+func useNonce(nonce string, ttl time.Duration) bool {
+	if config.Config.GetConfigString("go.sign.nonce") == "redis" {
+		conn, err := db.Redis.GetConnection(redisDbName()...)
+		if err == nil {
+			var ok bool
+			ok, err = conn.SetNX("mgin:sign:nonce:"+nonce, "1", ttl).Result()
+			if err == nil {
+				return ok
+			}
+		}
+		logs.Error("签名随机串写入Redis失败:{}", err.Error())
+	}
+	nonceLock.Lock()
+	defer nonceLock.Unlock()
+	if cache.OnGetCache("sign").IsExist(nonce) {
+		return false
+	}
+	cache.OnGetCache("sign").Add(nonce, true, ttl)
+	return true
+}
+
+func redisDbName() []string {
+	if db.Redis.IsMultiDB() {
+		return []string{config.Config.GetConfigString("go.sign.redisDb")}
+	}
+	return nil
+}
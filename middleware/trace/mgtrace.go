@@ -1,15 +1,57 @@
 package trace
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"strconv"
+	"time"
 )
 
+// TraceId 生成请求id并将请求作用域挂载到请求的ctx上
+// 按请求头中的traceparent创建服务端span，调用其他服务时以此span为父span
+// 请求头X-Timeout(秒)大于0时为请求的ctx设置超时，调用其他服务时沿链路传递剩余时间
+// go.application.routine_cache不为false时同时写入处理请求的协程的缓存，请求结束时清除
 func TraceId() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		PutRequestId(c)
+		ctx := tracing.Extract(c.Request.Context(), getHeaders(c))
+		ctx, span := tracing.Start(ctx, spanName(c), oteltrace.SpanKindServer,
+			semconv.HTTPServerAttributesFromHTTPRequest(config.Current().App.Name, c.FullPath(), c.Request)...)
+		defer func() {
+			status := c.Writer.Status()
+			span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+			span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(status, oteltrace.SpanKindServer))
+			if err := c.Errors.Last(); err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		}()
+		c.Request = c.Request.WithContext(ctx)
+
+		putRequestId(c)
+		if config.Current().App.RoutineCache {
+			defer BindRoutine(c)()
+		}
+		if t, _ := strconv.Atoi(c.GetHeader("X-Timeout")); t > 0 {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(t)*time.Second)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
 	}
 }
 
 func Headers() gin.HandlerFunc {
 	return TraceId()
 }
+
+// spanName 服务端span名称，使用路由模板避免路径参数导致名称过多
+func spanName(c *gin.Context) string {
+	if c.FullPath() == "" {
+		return "HTTP " + c.Request.Method
+	}
+	return c.Request.Method + " " + c.FullPath()
+}
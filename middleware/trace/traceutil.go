@@ -2,21 +2,47 @@ package trace
 
 import (
 	"bytes"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/maczh/mgin/cache"
+	"github.com/maczh/mgin/scope"
+	"github.com/maczh/mgin/tracing"
 	"math/rand"
 	"runtime"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
+// routineCached 是否有旧接口写入过协程缓存，没有时按协程id获取的旧接口无需获取协程id
+var routineCached int32
+
+// PutRequestId 生成请求id，将请求头保存到请求作用域与当前协程的缓存中
+//
+// Deprecated: TraceId中间件已将请求头保存到请求作用域，请通过GetHeadersFrom(ctx)获取
 func PutRequestId(c *gin.Context) {
+	putRequestId(c)
+	cacheRoutine(GetGoroutineID(), scope.FromContext(c).Headers)
+}
+
+// cacheRoutine 将请求头保存到协程缓存，只由按协程id获取的旧接口使用
+func cacheRoutine(routineId uint64, headers map[string]string) {
+	atomic.StoreInt32(&routineCached, 1)
+	cache.OnGetCache("Header").Add(routineId, headers, 5*time.Minute)
+}
+
+func putRequestId(c *gin.Context) {
 	headers := getHeaders(c)
 	requestId := headers["X-Request-Id"]
 	if requestId == "" {
-		headers["X-Request-Id"] = getRandomHexString(16)
+		//有链路追踪时使用trace id作为请求id，便于关联日志与链路
+		requestId = tracing.TraceId(c.Request.Context())
+		if requestId == "" {
+			requestId = getRandomHexString(16)
+		}
+		headers["X-Request-Id"] = requestId
 	}
-	routineId := GetGoroutineID()
+	tracing.Inject(c.Request.Context(), headers)
 	clientIp := c.ClientIP()
 	if c.GetHeader("X-Real-IP") != "" {
 		clientIp = c.GetHeader("X-Real-IP")
@@ -28,27 +54,100 @@ func PutRequestId(c *gin.Context) {
 	if headers["X-User-Agent"] == "" {
 		headers["X-User-Agent"] = headers["User-Agent"]
 	}
-	cache.OnGetCache("Header").Add(routineId, headers, 5*time.Minute)
+	s := scope.Attach(c)
+	s.RequestId = headers["X-Request-Id"]
+	s.Headers = headers
+}
+
+// ContextWithHeaders 以消息队列等非HTTP来源传递的请求头创建请求作用域，没有X-Request-Id时生成
+func ContextWithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	h := make(map[string]string)
+	for k, v := range headers {
+		h[k] = v
+	}
+	if h["X-Request-Id"] == "" {
+		h["X-Request-Id"] = tracing.TraceId(ctx)
+		if h["X-Request-Id"] == "" {
+			h["X-Request-Id"] = getRandomHexString(16)
+		}
+	}
+	tracing.Inject(ctx, h)
+	return scope.WithScope(ctx, &scope.Scope{RequestId: h["X-Request-Id"], Headers: h, Lang: h["X-Lang"]})
+}
+
+// BindRoutine 将ctx中的请求头保存到当前协程的缓存，供按协程id获取的旧接口与日志使用，返回恢复原缓存的函数
+//
+// Deprecated: 请将ctx传递到需要请求头的地方，通过GetHeadersFrom(ctx)获取
+func BindRoutine(ctx context.Context) func() {
+	routineId := GetGoroutineID()
+	restore := restoreRoutineCache("Header", routineId)
+	cacheRoutine(routineId, GetHeadersFrom(ctx))
+	return restore
 }
 
+// restoreRoutineCache 恢复协程缓存原有的内容，嵌套绑定时不影响外层
+func restoreRoutineCache(name string, routineId uint64) func() {
+	prev, found := cache.OnGetCache(name).Value(routineId)
+	return func() {
+		if found {
+			cache.OnGetCache(name).Add(routineId, prev, 5*time.Minute)
+		} else {
+			cache.OnGetCache(name).Delete(routineId)
+		}
+	}
+}
+
+// GetRequestIdFrom 获取ctx中的请求id，可直接传入*gin.Context
+func GetRequestIdFrom(ctx context.Context) string {
+	return scope.FromContext(ctx).Header("X-Request-Id")
+}
+
+// GetClientIpFrom 获取ctx中的客户端ip
+func GetClientIpFrom(ctx context.Context) string {
+	return scope.FromContext(ctx).Header("X-Real-IP")
+}
+
+// GetUserAgentFrom 获取ctx中的客户端User-Agent
+func GetUserAgentFrom(ctx context.Context) string {
+	return scope.FromContext(ctx).Header("X-User-Agent")
+}
+
+// GetHeaderFrom 获取ctx中的请求头
+func GetHeaderFrom(ctx context.Context, header string) string {
+	return scope.FromContext(ctx).Header(header)
+}
+
+// GetHeadersFrom 获取ctx中全部请求头的副本
+func GetHeadersFrom(ctx context.Context) map[string]string {
+	return scope.FromContext(ctx).CopyHeaders()
+}
+
+// Deprecated: 按协程id获取，跨协程无效，请使用GetRequestIdFrom(ctx)
 func GetRequestId() string {
 	return GetHeader("X-Request-Id")
 }
 
+// Deprecated: 按协程id获取，跨协程无效，请使用GetClientIpFrom(ctx)
 func GetClientIp() string {
 	return GetHeader("X-Real-IP")
 }
 
+// Deprecated: 按协程id获取，跨协程无效，请使用GetUserAgentFrom(ctx)
 func GetUserAgent() string {
 	return GetHeader("X-User-Agent")
 }
 
+// Deprecated: 按协程id获取，跨协程无效，请使用GetHeaderFrom(ctx, header)
 func GetHeader(header string) string {
 	headers := GetHeaders()
 	return headers[header]
 }
 
+// Deprecated: 按协程id获取，跨协程无效，请使用GetHeadersFrom(ctx)
 func GetHeaders() map[string]string {
+	if atomic.LoadInt32(&routineCached) == 0 {
+		return map[string]string{}
+	}
 	headers, found := cache.OnGetCache("Header").Value(GetGoroutineID())
 	if found {
 		h := headers.(map[string]string)
@@ -77,6 +176,7 @@ func getRandomHexString(l int) string {
 	return generateRandString(str, l)
 }
 
+// Deprecated: 请求作用域已改为通过ctx传递
 func GetGoroutineID() uint64 {
 	b := make([]byte, 64)
 	b = b[:runtime.Stack(b, false)]
@@ -94,10 +194,12 @@ func getHeaders(c *gin.Context) map[string]string {
 	return headers
 }
 
-//从其他协程克隆headers到当前协程的缓存
+// 从其他协程克隆headers到当前协程的缓存
+//
+// Deprecated: 请使用mgin.Go(ctx, fn)启动协程，通过GetHeadersFrom(ctx)获取
 func CopyPreHeaderToCurRoutine(preRoutineId uint64) {
 	headers, found := cache.OnGetCache("Header").Value(preRoutineId)
 	if found {
-		cache.OnGetCache("Header").Add(GetGoroutineID(), headers, 5*time.Minute)
+		cacheRoutine(GetGoroutineID(), headers.(map[string]string))
 	}
 }
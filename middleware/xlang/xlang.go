@@ -2,30 +2,74 @@ package xlang
 
 import (
 	"bytes"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/maczh/mgin/cache"
+	"github.com/maczh/mgin/config"
+	"github.com/maczh/mgin/scope"
 	"runtime"
 	"strconv"
+	"sync/atomic"
 	"time"
 )
 
+const defaultLanguage = "zh-cn"
+
+// RequestLanguage 从请求头X-Lang获取请求的语言，保存到请求作用域中
+// go.application.routine_cache不为false时同时写入处理请求的协程的缓存，请求结束时清除
 func RequestLanguage() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		lang := c.GetHeader("X-Lang")
 		if lang == "" {
-			lang = "zh-cn"
+			lang = defaultLanguage
+		}
+		scope.Attach(c).Lang = lang
+		if config.Current().App.RoutineCache {
+			defer BindRoutine(c)()
 		}
-		routineId := getGoroutineID()
-		cache.OnGetCache("Lang").Add(routineId, lang, 5*time.Minute)
+		c.Next()
 	}
 }
 
+// routineCached 是否写入过协程缓存，没有时GetCurrentLanguage无需获取协程id
+var routineCached int32
+
+// BindRoutine 将ctx中的语言保存到当前协程的缓存，供GetCurrentLanguage使用，返回恢复原缓存的函数
+//
+// Deprecated: 请将ctx传递到需要语言的地方，通过GetLanguageFrom(ctx)获取
+func BindRoutine(ctx context.Context) func() {
+	atomic.StoreInt32(&routineCached, 1)
+	routineId := getGoroutineID()
+	lang := GetLanguageFrom(ctx)
+	prev, found := cache.OnGetCache("Lang").Value(routineId)
+	cache.OnGetCache("Lang").Add(routineId, lang, 5*time.Minute)
+	return func() {
+		if found {
+			cache.OnGetCache("Lang").Add(routineId, prev, 5*time.Minute)
+		} else {
+			cache.OnGetCache("Lang").Delete(routineId)
+		}
+	}
+}
+
+// GetLanguageFrom 获取ctx中请求的语言，可直接传入*gin.Context
+func GetLanguageFrom(ctx context.Context) string {
+	if s := scope.FromContext(ctx); s != nil && s.Lang != "" {
+		return s.Lang
+	}
+	return defaultLanguage
+}
+
+// Deprecated: 按协程id获取，跨协程无效，请使用GetLanguageFrom(ctx)
 func GetCurrentLanguage() string {
+	if atomic.LoadInt32(&routineCached) == 0 {
+		return defaultLanguage
+	}
 	lang, found := cache.OnGetCache("Lang").Value(getGoroutineID())
 	if found {
 		return lang.(string)
 	} else {
-		return "zh-cn"
+		return defaultLanguage
 	}
 }
 
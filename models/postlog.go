@@ -15,9 +15,16 @@ type PostLog struct {
 	Method        string                 `json:"method" bson:"method"`
 	ContentType   string                 `json:"contentType" bson:"contentType"`
 	Uri           string                 `json:"uri" bson:"uri"`
+	Route         string                 `json:"route" bson:"route"`
+	Query         map[string]string      `json:"query" bson:"query"`
 	ClientIP      string                 `json:"clientIP" bson:"clientIP"`
 	RequestHeader map[string]string      `json:"requestHeader" bson:"requestHeader"`
 	RequestParam  interface{}            `json:"requestParam" bson:"requestParam"`
+	StatusCode    int                    `json:"statusCode" bson:"statusCode"`
+	ResponseSize  int                    `json:"responseSize" bson:"responseSize"`
 	ResponseStr   string                 `json:"responseStr" bson:"responseStr"`
 	ResponseMap   map[string]interface{} `json:"responseMap" bson:"responseMap"`
+	Panic         string                 `json:"panic" bson:"panic"`
+	Stack         string                 `json:"stack" bson:"stack"`
+	Errors        []string               `json:"errors" bson:"errors"`
 }
@@ -158,6 +158,15 @@ func (n *NacosClient) Register(nacosConfigUrl string) {
 }
 
 func (n *NacosClient) GetServiceURL(servicename string) (string, string) {
+	urls, serviceGroup := n.GetServiceURLs(servicename)
+	if len(urls) == 0 {
+		return "", serviceGroup
+	}
+	return urls[len(urls)-1], serviceGroup
+}
+
+// GetServiceURLs 获取服务全部健康实例的地址，不含调试实例
+func (n *NacosClient) GetServiceURLs(servicename string) ([]string, string) {
 	var instances []model.Instance
 	var err error
 	serviceGroup := n.group
@@ -175,10 +184,9 @@ func (n *NacosClient) GetServiceURL(servicename string) (string, string) {
 		})
 		if err != nil {
 			logger.Error("获取Nacos服务" + servicename + "失败:" + err.Error())
-			return "", ""
+			return nil, ""
 		}
 	}
-	url := ""
 	urls := make([]string, 0)
 	for _, instance := range instances {
 		if instance.Metadata != nil && instance.Metadata["debug"] == "true" {
@@ -187,7 +195,7 @@ func (n *NacosClient) GetServiceURL(servicename string) (string, string) {
 		if !instance.Healthy {
 			continue
 		}
-		url = "http://" + instance.Ip + ":" + strconv.Itoa(int(instance.Port))
+		url := "http://" + instance.Ip + ":" + strconv.Itoa(int(instance.Port))
 		if instance.Metadata != nil && instance.Metadata["ssl"] == "true" {
 			url = "https://" + instance.Ip + ":" + strconv.Itoa(int(instance.Port))
 		}
@@ -195,7 +203,7 @@ func (n *NacosClient) GetServiceURL(servicename string) (string, string) {
 		logger.Debug("Nacos获取" + servicename + "服务成功:" + url)
 	}
 	cache.OnGetCache("nacos").Add(servicename, strings.Join(urls, ","), 5*time.Minute)
-	return url, serviceGroup
+	return urls, serviceGroup
 }
 
 func (n *NacosClient) DeRegister() {
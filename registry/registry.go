@@ -3,8 +3,40 @@ package registry
 import (
 	"github.com/maczh/mgin/registry/nacos"
 	"github.com/nacos-group/nacos-sdk-go/vo"
+	"sync"
 )
 
 var Nacos = &nacos.NacosClient{
 	Subscribes: make(map[string]*vo.SubscribeParam),
 }
+
+// Discovery 服务发现接口，返回服务的访问地址与所在分组
+type Discovery interface {
+	GetServiceURL(serviceName string) (string, string)
+}
+
+// MultiDiscovery 可返回服务全部健康实例地址的服务发现，对冲请求等需要多个实例时使用
+type MultiDiscovery interface {
+	GetServiceURLs(serviceName string) ([]string, string)
+}
+
+var (
+	lock        sync.RWMutex
+	discoveries = map[string]Discovery{
+		"nacos": Nacos,
+	}
+)
+
+// Register 注册服务发现实现，name对应配置项go.discovery.registry
+func Register(name string, d Discovery) {
+	lock.Lock()
+	defer lock.Unlock()
+	discoveries[name] = d
+}
+
+// Get 获取已注册的服务发现实现
+func Get(name string) Discovery {
+	lock.RLock()
+	defer lock.RUnlock()
+	return discoveries[name]
+}
@@ -0,0 +1,89 @@
+// Package scope 基于context.Context的请求作用域，保存请求id、透传的header与语言
+// 替代按协程id缓存的方式，可随ctx跨协程传递，请求结束即释放
+package scope
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"time"
+)
+
+// Scope 一次请求的作用域，由trace与xlang中间件在请求开始时填充，之后只读
+type Scope struct {
+	RequestId string
+	Headers   map[string]string
+	Lang      string
+}
+
+type scopeKey struct{}
+
+// WithScope 返回携带请求作用域的ctx
+func WithScope(ctx context.Context, s *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// FromContext 获取ctx中的请求作用域，可直接传入*gin.Context，没有时返回nil
+func FromContext(ctx context.Context) *Scope {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := RequestContext(ctx).Value(scopeKey{}).(*Scope)
+	return s
+}
+
+// RequestContext 传入*gin.Context时返回其请求的ctx，以便获取请求的超时与取消，其他ctx原样返回
+func RequestContext(ctx context.Context) context.Context {
+	if c, ok := ctx.(*gin.Context); ok && c.Request != nil {
+		return c.Request.Context()
+	}
+	return ctx
+}
+
+// Detach 保留ctx中的请求作用域与链路信息，不继承超时与取消，用于请求结束后仍需执行的操作
+func Detach(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return detachedContext{RequestContext(ctx)}
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (d detachedContext) Value(key interface{}) interface{} {
+	return d.parent.Value(key)
+}
+
+// Attach 获取gin请求的作用域，没有时创建并挂载到请求的ctx上
+func Attach(c *gin.Context) *Scope {
+	if s := FromContext(c); s != nil {
+		return s
+	}
+	s := &Scope{Headers: make(map[string]string)}
+	c.Request = c.Request.WithContext(WithScope(c.Request.Context(), s))
+	return s
+}
+
+// Header 获取请求头，s为nil时返回空
+func (s *Scope) Header(name string) string {
+	if s == nil {
+		return ""
+	}
+	return s.Headers[name]
+}
+
+// CopyHeaders 获取请求头的副本，s为nil时返回空map
+func (s *Scope) CopyHeaders() map[string]string {
+	headers := make(map[string]string)
+	if s == nil {
+		return headers
+	}
+	for k, v := range s.Headers {
+		headers[k] = v
+	}
+	return headers
+}
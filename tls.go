@@ -0,0 +1,57 @@
+package mgin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/maczh/mgin/config"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSConfig 根据go.application下的cert、key、ca配置生成HTTPS服务端的TLS配置
+// 当go.application.mtls为true时，要求调用方出示由ca签发的客户端证书
+func (m *mgin) TLSConfig() (*tls.Config, error) {
+	if config.Config.App.Cert == "" || config.Config.App.Key == "" {
+		return nil, errors.New("未配置SSL证书或私钥")
+	}
+	cert, err := tls.LoadX509KeyPair(config.Config.AbsPath(config.Config.App.Cert), config.Config.AbsPath(config.Config.App.Key))
+	if err != nil {
+		return nil, fmt.Errorf("加载SSL证书失败:%s", err.Error())
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if !config.Config.App.MTLS {
+		return tlsConfig, nil
+	}
+	if config.Config.App.CA == "" {
+		return nil, errors.New("双向认证未配置CA证书")
+	}
+	ca, err := ioutil.ReadFile(config.Config.AbsPath(config.Config.App.CA))
+	if err != nil {
+		return nil, fmt.Errorf("读取CA证书失败:%s", err.Error())
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("CA证书格式错误")
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// ListenAndServeTLS 按TLSConfig的配置启动HTTPS服务，server未设置Addr时使用go.application.port_ssl
+func (m *mgin) ListenAndServeTLS(server *http.Server) error {
+	tlsConfig, err := m.TLSConfig()
+	if err != nil {
+		return err
+	}
+	if server.Addr == "" {
+		server.Addr = fmt.Sprintf(":%d", config.Config.App.PortSSL)
+	}
+	server.TLSConfig = tlsConfig
+	return server.ListenAndServeTLS("", "")
+}
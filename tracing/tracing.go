@@ -0,0 +1,179 @@
+// Package tracing 基于OpenTelemetry的链路追踪，按W3C Trace Context(traceparent/tracestate)在服务间传递
+// 在go.trace.exporter中配置导出方式后开启，未开启时只透传上游的traceparent，不产生span
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/maczh/mgin/config"
+	"github.com/sadlil/gologger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 本框架产生的span所用的Tracer名称
+const instrumentationName = "github.com/maczh/mgin"
+
+var (
+	logger   = gologger.GetLogger()
+	provider *sdktrace.TracerProvider
+	traceOut *os.File
+)
+
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Init 按go.trace配置创建TracerProvider
+// exporter为otlp时通过HTTP导出到endpoint，stdout输出到控制台，file写入file指定的文件供离线分析
+// sampler为采样比例，默认为1全部采样，上游已采样的请求始终采样
+func Init() {
+	exporter := config.Config.GetConfigString("go.trace.exporter")
+	if exporter == "" {
+		return
+	}
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "otlp":
+		opts := []otlptracehttp.Option{}
+		if endpoint := config.Config.GetConfigString("go.trace.endpoint"); endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+		if config.Config.GetConfigBool("go.trace.insecure") {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		spanExporter, err = otlptracehttp.New(context.Background(), opts...)
+	case "stdout":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case "file":
+		file := config.Config.GetConfigString("go.trace.file")
+		if file == "" {
+			file = "trace.json"
+		}
+		file = config.Config.AbsPath(file)
+		os.MkdirAll(filepath.Dir(file), 0755)
+		traceOut, err = os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(traceOut))
+		}
+	default:
+		err = errors.New("不支持的导出方式:" + exporter)
+	}
+	if err != nil {
+		logger.Error("链路追踪初始化失败:" + err.Error())
+		return
+	}
+	ratio := 1.0
+	if config.Config.Exists("go.trace.sampler") {
+		ratio = config.Config.Cnf.Float64("go.trace.sampler")
+	}
+	res, _ := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(config.Config.App.Name),
+		semconv.DeploymentEnvironmentKey.String(config.Config.Config.Env),
+	))
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	logger.Info("链路追踪已开启，导出方式:" + exporter)
+}
+
+// Shutdown 导出剩余的span并关闭TracerProvider
+func Shutdown() {
+	if provider == nil {
+		return
+	}
+	if err := provider.Shutdown(context.Background()); err != nil {
+		logger.Error("链路追踪关闭失败:" + err.Error())
+	}
+	if traceOut != nil {
+		traceOut.Close()
+	}
+}
+
+// Enabled 是否已开启链路追踪
+func Enabled() bool {
+	return provider != nil
+}
+
+// Tracer 获取本框架使用的Tracer，未开启时为不记录的Tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start 以ctx中的span为父span创建新的span
+func Start(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// End 结束span，err不为nil时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// StartDB 创建数据库操作的span，返回结束span的函数，用于没有钩子的数据库驱动，如mgo
+//
+//	ctx, end := tracing.StartDB(ctx, "mongodb", "find", "user")
+//	err := c.Find(query).All(&users)
+//	end(err)
+func StartDB(ctx context.Context, system, operation, target string) (context.Context, func(err error)) {
+	ctx, span := Start(ctx, system+"."+operation, trace.SpanKindClient,
+		semconv.DBSystemKey.String(system),
+		semconv.DBOperationKey.String(operation),
+		attribute.String("db.target", target),
+	)
+	return ctx, func(err error) {
+		End(span, err)
+	}
+}
+
+// Inject 将ctx中的链路信息写入header，覆盖上游传入的traceparent与tracestate
+func Inject(ctx context.Context, headers map[string]string) {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for k, v := range carrier {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+}
+
+// InjectHTTP 将ctx中的链路信息写入HTTP请求头
+func InjectHTTP(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract 从header中读取上游的链路信息，header名称不区分大小写
+func Extract(ctx context.Context, headers map[string]string) context.Context {
+	carrier := propagation.MapCarrier{}
+	for k, v := range headers {
+		carrier[strings.ToLower(k)] = v
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// TraceId 获取ctx中的trace id，没有时返回空
+func TraceId(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
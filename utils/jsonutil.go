@@ -3,7 +3,9 @@ package utils
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"github.com/maczh/mgin/logs"
+	"io"
 	"strings"
 )
 
@@ -30,6 +32,19 @@ func FromJSON(j string, o interface{}) *interface{} {
 	}
 }
 
+// UnmarshalNumber 解析json，数字解析为json.Number，避免超过2^53的整数转为float64后丢失精度
+func UnmarshalNumber(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("json数据后有多余内容")
+	}
+	return nil
+}
+
 // JSONPrettyPrint pretty print raw json string to indent string
 func JSONPretty(in, prefix, indent string) string {
 	var out bytes.Buffer
@@ -47,7 +47,10 @@ func (receiver *safeGo) SetCallBeforeHandler(callBeforeF func(params map[string]
 
 // Run 运行
 func (receiver *safeGo) Run(args ...interface{}) {
-	preRoutineId := receiver.goBeforeF()
+	var preRoutineId map[string]interface{}
+	if receiver.goBeforeF != nil {
+		preRoutineId = receiver.goBeforeF()
+	}
 	go func() {
 		defer func() {
 			if err := recover(); err != nil {
@@ -57,7 +60,9 @@ func (receiver *safeGo) Run(args ...interface{}) {
 					goErr.Error(), goErr.Stack(), reset)
 			}
 		}()
-		receiver.callBeforeF(preRoutineId)
+		if receiver.callBeforeF != nil {
+			receiver.callBeforeF(preRoutineId)
+		}
 		receiver.argsF(args...)
 	}()
 }
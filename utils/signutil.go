@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// 微服务间请求签名使用的请求头
+const (
+	HeaderSignature = "X-Signature"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderNonce     = "X-Nonce"
+	HeaderCaller    = "X-Caller"
+	//请求体的sha256，流式上传时为UnsignedPayload，请求体不参与签名
+	HeaderContentSha256 = "X-Content-Sha256"
+	UnsignedPayload     = "UNSIGNED-PAYLOAD"
+)
+
+// RequestSignString 生成待签名字符串，依次为请求方法、路径、按key排序的query、请求体sha256、时间戳与随机串，以换行分隔
+func RequestSignString(method, path string, query url.Values, bodyHash, timestamp, nonce string) string {
+	return strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		query.Encode(),
+		bodyHash,
+		timestamp,
+		nonce,
+	}, "\n")
+}
+
+// RequestSign 使用HmacSHA256对请求签名
+func RequestSign(key, method, path string, query url.Values, bodyHash, timestamp, nonce string) string {
+	return HmacSHA256Hex(key, RequestSignString(method, path, query, bodyHash, timestamp, nonce))
+}